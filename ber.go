@@ -2,6 +2,7 @@ package ber
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -28,6 +29,29 @@ const (
 	Constructed
 )
 
+// Rules selects the set of encoding rules applied by an Encoder or expected by
+// a Decoder.
+type Rules uint8
+
+const (
+	BER Rules = iota
+	DER
+	CER
+)
+
+func (r Rules) String() string {
+	switch r {
+	case BER:
+		return "BER"
+	case DER:
+		return "DER"
+	case CER:
+		return "CER"
+	default:
+		return "unknown"
+	}
+}
+
 var (
 	Bool            Ident = NewPrimitive(0x01)
 	Int                   = NewPrimitive(0x02)
@@ -50,6 +74,12 @@ var (
 const (
 	patGeneralTime = "20060102150405-0700"
 	patUniversTime = "060102150405-0700"
+
+	patGeneralTimeZ = "20060102150405Z"
+	patUniversTimeZ = "060102150405Z"
+
+	patGeneralTimeParse = "20060102150405Z0700"
+	patUniversTimeParse = "060102150405Z0700"
 )
 
 type Raw []byte
@@ -85,6 +115,53 @@ func (i Ident) Tag() uint32 {
 	return uint32(val)
 }
 
+var classNames = []string{"UNIVERSAL", "APPLICATION", "CONTEXT", "PRIVATE"}
+
+// String gives the class and the tag number of i (eg: [CONTEXT 3]).
+func (i Ident) String() string {
+	return fmt.Sprintf("[%s %d]", classNames[i.Class()], i.Tag())
+}
+
+var universalNames = map[uint32]string{
+	0x00: "EOC",
+	0x01: "BOOLEAN",
+	0x02: "INTEGER",
+	0x03: "BIT STRING",
+	0x04: "OCTET STRING",
+	0x05: "NULL",
+	0x06: "OBJECT IDENTIFIER",
+	0x07: "ObjectDescriptor",
+	0x08: "EXTERNAL",
+	0x09: "REAL",
+	0x0a: "ENUMERATED",
+	0x0b: "EMBEDDED PDV",
+	0x0c: "UTF8String",
+	0x0d: "RELATIVE-OID",
+	0x10: "SEQUENCE",
+	0x11: "SET",
+	0x12: "NumericString",
+	0x13: "PrintableString",
+	0x14: "TeletexString",
+	0x15: "VideotexString",
+	0x16: "IA5String",
+	0x17: "UTCTime",
+	0x18: "GeneralizedTime",
+	0x19: "GraphicString",
+	0x1a: "VisibleString",
+	0x1b: "GeneralString",
+	0x1c: "UniversalString",
+	0x1e: "BMPString",
+}
+
+// Name gives the name of the universal type identified by i (eg: INTEGER) or
+// an empty string if i is not the identifier of a known universal type.
+func (i Ident) Name() string {
+	if i.Class() != Universal {
+		return ""
+	}
+	return universalNames[i.Tag()]
+}
+
 func (i Ident) Primitive() Ident {
 	v := uint64(i) | (uint64(Primitive) << 32)
 	return Ident(v)
@@ -119,6 +196,10 @@ func (i Ident) isZero() bool {
 	return i == 0
 }
 
+func (i Ident) isSet() bool {
+	return i.Class() == Universal && i.Tag() == Set.Tag()
+}
+
 func (i Ident) setTag(tag uint32) Ident {
 	v := uint64(i.clearTag()) | uint64(tag)
 	return Ident(v)
@@ -192,6 +273,8 @@ func parseTag(str string, i Ident) (Ident, bool, error) {
 			i = UTF8String
 		case str == "octetstr":
 			i = OctetString
+		case str == "bitstring":
+			i = BitString
 		case str == "oid":
 			i = ObjectId
 		case str == "roid":
@@ -202,6 +285,91 @@ func parseTag(str string, i Ident) (Ident, bool, error) {
 	return i, omit, nil
 }
 
+// fieldOptions holds the options of a field tag that do not change the
+// identifier of the field.
+type fieldOptions struct {
+	// the element of the field can be absent
+	optional bool
+	// value of the field when its element is absent
+	def    string
+	hasDef bool
+	// constraints on the size and on the value of the field used by PER
+	size  constraint
+	value constraint
+}
+
+func parseOptions(str string) (fieldOptions, error) {
+	var (
+		opts fieldOptions
+		err  error
+	)
+	for _, str := range strings.Split(str, ",") {
+		switch {
+		case str == "optional":
+			opts.optional = true
+		case strings.HasPrefix(str, "default:"):
+			opts.def = strings.TrimSpace(strings.TrimPrefix(str, "default:"))
+			opts.hasDef = true
+		case strings.HasPrefix(str, "size:"):
+			opts.size, err = parseConstraint(strings.TrimPrefix(str, "size:"))
+			if err == nil && opts.size.hasLB && opts.size.lb < 0 {
+				err = fmt.Errorf("size: negative lower bound %d", opts.size.lb)
+			}
+		case strings.HasPrefix(str, "range:"):
+			opts.value, err = parseConstraint(strings.TrimPrefix(str, "range:"))
+		}
+		if err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// canBeAbsent reports whether the element of a field can be missing from the
+// encoding of its struct.
+func (o fieldOptions) canBeAbsent() bool {
+	return o.optional || o.hasDef
+}
+
+// defaultValue gives the default value of a field of type typ from the default
+// option of its tag.
+func defaultValue(typ reflect.Type, str string) (reflect.Value, error) {
+	var (
+		val = reflect.New(typ).Elem()
+		err error
+	)
+	switch k := typ.Kind(); {
+	case k == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(str); err == nil {
+			val.SetBool(b)
+		}
+	case k >= reflect.Int && k <= reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(str, 0, typ.Bits()); err == nil {
+			val.SetInt(i)
+		}
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		var i uint64
+		if i, err = strconv.ParseUint(str, 0, typ.Bits()); err == nil {
+			val.SetUint(i)
+		}
+	case k == reflect.Float32 || k == reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(str, typ.Bits()); err == nil {
+			val.SetFloat(f)
+		}
+	case k == reflect.String:
+		val.SetString(str)
+	default:
+		err = fmt.Errorf("default value not supported for %s", typ)
+	}
+	if err != nil {
+		return val, fmt.Errorf("default: %w", err)
+	}
+	return val, nil
+}
+
 func ValidPrintableString(str string) bool {
 	isLetter := func(r rune) bool {
 		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
@@ -0,0 +1,101 @@
+package ber
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+var (
+	bigtype = reflect.TypeOf(big.Int{})
+	bigOne  = big.NewInt(1)
+)
+
+func (e *Encoder) EncodeBigInt(val *big.Int) error {
+	return e.EncodeBigIntWithIdent(val, Int)
+}
+
+// EncodeBigIntWithIdent encodes val as an INTEGER with the identifier tag. A
+// nil val is written as a NULL like the other nil pointers: the identifier of a
+// NULL replaces the universal INTEGER one.
+func (e *Encoder) EncodeBigIntWithIdent(val *big.Int, tag Ident) error {
+	if val == nil {
+		if tag == Int {
+			tag = Null
+		}
+		return e.EncodeNullWithIdent(tag)
+	}
+	if tag.isZero() {
+		tag = Int
+	}
+	if tag.Type() != Primitive {
+		return encodeError(tag, fmt.Errorf("int: %w", ErrPrimitive))
+	}
+	return e.encodeBytes(encodeBigInt(val), tag)
+}
+
+func (d *Decoder) DecodeBigInt() (_ *big.Int, err error) {
+	if err := d.fill(); err != nil {
+		return nil, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	if id.Type() != Primitive {
+		return nil, fmt.Errorf("int: %w", ErrPrimitive)
+	}
+	d.offset += n
+	size, n, err := d.decodeLength()
+	if err != nil {
+		return nil, err
+	}
+	d.offset += n
+	if err := d.checkInt(d.buf[d.offset : d.offset+size]); err != nil {
+		return nil, err
+	}
+	d.offset += size
+	return decodeBigInt(d.buf[d.offset-size : d.offset]), nil
+}
+
+// encodeBigInt encodes i in the minimal number of octets of its two's
+// complement representation.
+func encodeBigInt(i *big.Int) []byte {
+	if i.Sign() >= 0 {
+		b := i.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0x00}, b...)
+		}
+		return b
+	}
+	// the two's complement of a negative number -x is the bitwise complement
+	// of x-1.
+	x := new(big.Int).Neg(i)
+	x.Sub(x, bigOne)
+	b := x.Bytes()
+	for j := range b {
+		b[j] = ^b[j]
+	}
+	if len(b) == 0 || b[0]&0x80 == 0 {
+		b = append([]byte{0xFF}, b...)
+	}
+	return b
+}
+
+func decodeBigInt(b []byte) *big.Int {
+	i := new(big.Int)
+	if len(b) == 0 {
+		return i
+	}
+	if b[0]&0x80 == 0 {
+		return i.SetBytes(b)
+	}
+	c := make([]byte, len(b))
+	for j := range b {
+		c[j] = ^b[j]
+	}
+	i.SetBytes(c)
+	i.Add(i, bigOne)
+	return i.Neg(i)
+}
@@ -0,0 +1,206 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestBigInt(t *testing.T) {
+	t.Run("encode", testEncodeBigInt)
+	t.Run("decode", testDecodeBigInt)
+	t.Run("struct", testBigIntStruct)
+	t.Run("overflow", testDecodeOverflow)
+	t.Run("nil", testEncodeNilBigInt)
+}
+
+func bigInt(str string) *big.Int {
+	i, ok := new(big.Int).SetString(str, 0)
+	if !ok {
+		panic("invalid big int " + str)
+	}
+	return i
+}
+
+var bigData = []struct {
+	Input *big.Int
+	Want  []byte
+}{
+	{Input: big.NewInt(0), Want: []byte{0x02, 0x01, 0x00}},
+	{Input: big.NewInt(127), Want: []byte{0x02, 0x01, 0x7f}},
+	{Input: big.NewInt(128), Want: []byte{0x02, 0x02, 0x00, 0x80}},
+	{Input: big.NewInt(-1), Want: []byte{0x02, 0x01, 0xff}},
+	{Input: big.NewInt(-128), Want: []byte{0x02, 0x01, 0x80}},
+	{Input: big.NewInt(-129), Want: []byte{0x02, 0x02, 0xff, 0x7f}},
+	{Input: big.NewInt(-256), Want: []byte{0x02, 0x02, 0xff, 0x00}},
+	{
+		Input: bigInt("0x0102030405060708090a"),
+		Want:  []byte{0x02, 0x0a, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a},
+	},
+	{
+		Input: bigInt("-0x8000000000000000000000"),
+		Want:  []byte{0x02, 0x0b, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	},
+}
+
+func testEncodeBigInt(t *testing.T) {
+	for _, d := range bigData {
+		var e Encoder
+		if err := e.Encode(d.Input); err != nil {
+			t.Errorf("%s: fail to encode big int! %s", d.Input, err)
+			continue
+		}
+		if got := e.Bytes(); !bytes.Equal(got, d.Want) {
+			t.Errorf("%s: bytes mismatched! want %x, got %x", d.Input, d.Want, got)
+		}
+	}
+}
+
+func testDecodeBigInt(t *testing.T) {
+	for _, d := range bigData {
+		var got big.Int
+		if err := NewDecoder(d.Want).Decode(&got); err != nil {
+			t.Errorf("%x: fail to decode big int! %s", d.Want, err)
+			continue
+		}
+		if got.Cmp(d.Input) != 0 {
+			t.Errorf("%x: big int mismatched! want %s, got %s", d.Want, d.Input, &got)
+		}
+	}
+}
+
+func testBigIntStruct(t *testing.T) {
+	type serial struct {
+		Value  big.Int
+		Ptr    *big.Int
+		Simple int64
+	}
+	in := serial{Ptr: bigInt("0xffffffffffffffffff"), Simple: -2}
+	in.Value.SetInt64(-300)
+
+	var e Encoder
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("struct: fail to encode! %s", err)
+	}
+	want := []byte{
+		0x30, 0x13,
+		0x02, 0x02, 0xfe, 0xd4,
+		0x02, 0x0a, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x02, 0x01, 0xfe,
+	}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("struct: bytes mismatched! want %x, got %x", want, got)
+	}
+	var out serial
+	if err := NewDecoder(want).Decode(&out); err != nil {
+		t.Fatalf("struct: fail to decode! %s", err)
+	}
+	if out.Value.Cmp(&in.Value) != 0 || out.Ptr == nil || out.Ptr.Cmp(in.Ptr) != 0 || out.Simple != in.Simple {
+		t.Errorf("struct: values mismatched! want %+v, got %+v", in, out)
+	}
+}
+
+func testDecodeOverflow(t *testing.T) {
+	large := []byte{0x02, 0x09, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if _, err := NewDecoder(large).DecodeInt(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("int: expected overflow error, got %v", err)
+	}
+	if _, err := NewDecoder(large).DecodeUint(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("uint: expected overflow error, got %v", err)
+	}
+	negative := []byte{0x02, 0x01, 0xff}
+	if _, err := NewDecoder(negative).DecodeUint(); !errors.Is(err, ErrOverflow) {
+		t.Errorf("uint: expected overflow error for negative value, got %v", err)
+	}
+	max := []byte{0x02, 0x09, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if x, err := NewDecoder(max).DecodeUint(); err != nil || x != 1<<64-1 {
+		t.Errorf("uint: fail to decode max uint64 (%d)! %v", x, err)
+	}
+
+	var (
+		i8  int8
+		u16 uint16
+		i32 int32
+	)
+	if err := NewDecoder([]byte{0x02, 0x02, 0x00, 0x80}).Decode(&i8); !errors.Is(err, ErrOverflow) {
+		t.Errorf("int8: expected overflow error, got %v", err)
+	}
+	if err := NewDecoder([]byte{0x02, 0x01, 0x80}).Decode(&i8); err != nil || i8 != -128 {
+		t.Errorf("int8: fail to decode -128 (%d)! %v", i8, err)
+	}
+	if err := NewDecoder([]byte{0x02, 0x03, 0x01, 0x00, 0x00}).Decode(&u16); !errors.Is(err, ErrOverflow) {
+		t.Errorf("uint16: expected overflow error, got %v", err)
+	}
+	var s struct{ Value int32 }
+	if err := NewDecoder([]byte{0x30, 0x07, 0x02, 0x05, 0x01, 0x00, 0x00, 0x00, 0x00}).Decode(&s); !errors.Is(err, ErrOverflow) {
+		t.Errorf("struct: expected overflow error, got %v", err)
+	}
+	if err := NewDecoder([]byte{0x02, 0x04, 0x80, 0x00, 0x00, 0x00}).Decode(&i32); err != nil || i32 != -1<<31 {
+		t.Errorf("int32: fail to decode min int32 (%d)! %v", i32, err)
+	}
+
+	next := append(append([]byte{}, large...), 0x02, 0x01, 0x2a)
+	for _, unsigned := range []bool{false, true} {
+		var (
+			dec = NewDecoder(next)
+			err error
+		)
+		if unsigned {
+			_, err = dec.DecodeUint()
+		} else {
+			_, err = dec.DecodeInt()
+		}
+		if !errors.Is(err, ErrOverflow) {
+			t.Errorf("next: expected overflow error, got %v", err)
+			continue
+		}
+		if x, err := dec.DecodeInt(); err != nil || x != 42 {
+			t.Errorf("next: fail to decode element after overflow (%d)! %v", x, err)
+		}
+	}
+}
+
+func testEncodeNilBigInt(t *testing.T) {
+	data := []struct {
+		Name string
+		Func func(*Encoder) error
+		Want []byte
+	}{
+		{
+			Name: "encode",
+			Func: func(e *Encoder) error { return e.Encode((*big.Int)(nil)) },
+			Want: []byte{0x05, 0x00},
+		},
+		{
+			Name: "bigint",
+			Func: func(e *Encoder) error { return e.EncodeBigInt(nil) },
+			Want: []byte{0x05, 0x00},
+		},
+		{
+			Name: "tagged",
+			Func: func(e *Encoder) error { return e.EncodeBigIntWithIdent(nil, NewPrimitive(1).Context()) },
+			Want: []byte{0x81, 0x00},
+		},
+	}
+	for _, d := range data {
+		var e Encoder
+		if err := d.Func(&e); err != nil {
+			t.Errorf("%s: fail to encode nil big int! %s", d.Name, err)
+			continue
+		}
+		if got := e.Bytes(); !bytes.Equal(got, d.Want) {
+			t.Errorf("%s: bytes mismatched! want %x, got %x", d.Name, d.Want, got)
+		}
+	}
+	var s struct {
+		Value *big.Int
+	}
+	buf, err := encodeValue(s)
+	if err != nil {
+		t.Fatalf("struct: fail to encode nil big int! %s", err)
+	}
+	if err := NewDecoder(buf).Decode(&s); err != nil || s.Value != nil {
+		t.Errorf("struct: fail to decode nil big int (%v)! %v", s.Value, err)
+	}
+}
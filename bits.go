@@ -0,0 +1,227 @@
+package ber
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Bits is the Go representation of a BIT STRING. Its bits are numbered from
+// the most significant bit of the first byte.
+type Bits struct {
+	Bytes     []byte
+	BitLength int
+}
+
+// At gives the value of the bit at position i. Bits outside of the string are
+// reported as 0.
+func (b Bits) At(i int) int {
+	if i < 0 || i >= b.BitLength {
+		return 0
+	}
+	x, y := i/8, 7-uint(i%8)
+	return int(b.Bytes[x]>>y) & 1
+}
+
+// Set sets the bit at position i if on is true or clears it otherwise. The
+// string is extended when i is greater than its length.
+func (b *Bits) Set(i int, on bool) {
+	if i < 0 {
+		return
+	}
+	if i >= b.BitLength {
+		for len(b.Bytes) <= i/8 {
+			b.Bytes = append(b.Bytes, 0)
+		}
+		b.BitLength = i + 1
+	}
+	x, y := i/8, 7-uint(i%8)
+	if on {
+		b.Bytes[x] |= 1 << y
+	} else {
+		b.Bytes[x] &^= 1 << y
+	}
+}
+
+// RightAlign gives the bytes of the string shifted so that its unused bits are
+// at the start of the first byte instead of the end of the last byte.
+func (b Bits) RightAlign() []byte {
+	shift := uint(8 - (b.BitLength % 8))
+	if shift == 8 || len(b.Bytes) == 0 {
+		return b.Bytes
+	}
+	a := make([]byte, len(b.Bytes))
+	a[0] = b.Bytes[0] >> shift
+	for i := 1; i < len(b.Bytes); i++ {
+		a[i] = b.Bytes[i-1] << (8 - shift)
+		a[i] |= b.Bytes[i] >> shift
+	}
+	return a
+}
+
+var bitstringtype = reflect.TypeOf(Bits{})
+
+func (e *Encoder) EncodeBitString(val Bits) error {
+	return e.EncodeBitStringWithIdent(val, BitString)
+}
+
+func (e *Encoder) EncodeBitStringWithIdent(val Bits, tag Ident) error {
+	if tag.isZero() {
+		tag = BitString
+	}
+	if e.rules != BER && tag.Type() == Constructed {
+		return encodeError(tag, fmt.Errorf("bitstring: %w", ErrPrimitive))
+	}
+	b, err := encodeBitString(val)
+	if err != nil {
+		return encodeError(tag, err)
+	}
+	if e.rules == CER && len(b) > cerSegmentSize {
+		return e.encodeBitSegments(b, tag)
+	}
+	return e.encodeBytes(b, tag)
+}
+
+// encodeBitSegments encodes the contents of a bit string as a constructed
+// string made of BIT STRING segments of cerSegmentSize octets. Only the last
+// segment can have unused bits.
+func (e *Encoder) encodeBitSegments(b []byte, tag Ident) error {
+	var (
+		ex     = e.child()
+		unused = b[0]
+		data   = b[1:]
+		chunk  = make([]byte, 0, cerSegmentSize)
+	)
+	for len(data) > 0 {
+		var (
+			n = cerSegmentSize - 1
+			u byte
+		)
+		if len(data) <= n {
+			n, u = len(data), unused
+		}
+		chunk = append(chunk[:0], u)
+		chunk = append(chunk, data[:n]...)
+		if err := ex.encodeBytes(chunk, BitString); err != nil {
+			e.err = err
+			return e.err
+		}
+		data = data[n:]
+	}
+	return e.merge(&ex, tag.Constructed())
+}
+
+func (d *Decoder) DecodeBitString() (_ Bits, err error) {
+	var bs Bits
+	if err := d.fill(); err != nil {
+		return bs, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
+	if err != nil {
+		return bs, err
+	}
+	if err := d.checkPrimitive(id); err != nil {
+		return bs, err
+	}
+	d.offset += n
+	size, n, err := d.decodeLength()
+	if err != nil {
+		return bs, err
+	}
+	d.offset += n
+	if id.Type() == Constructed {
+		return d.decodeBitSegments(size)
+	}
+	if err := d.checkSegment(size); err != nil {
+		return bs, err
+	}
+	d.offset += size
+	return d.decodeBitString(d.buf[d.offset-size : d.offset])
+}
+
+func (d *Decoder) decodeBitString(b []byte) (Bits, error) {
+	var bs Bits
+	if len(b) == 0 {
+		return bs, fmt.Errorf("bitstring: missing unused bits octet")
+	}
+	unused := int(b[0])
+	if unused > 7 || (len(b) == 1 && unused > 0) {
+		return bs, fmt.Errorf("bitstring: invalid number of unused bits (%d)", unused)
+	}
+	if d.rules != BER && unused > 0 && b[len(b)-1]&(1<<uint(unused)-1) != 0 {
+		return bs, d.rulesError(d.offset, "unused bits of bitstring should be zero")
+	}
+	bs.Bytes = append([]byte{}, b[1:]...)
+	bs.BitLength = (len(b)-1)*8 - unused
+	return bs, nil
+}
+
+func (d *Decoder) decodeBitSegments(size int) (Bits, error) {
+	var (
+		bs    Bits
+		limit = d.limit(size)
+		last  = cerSegmentSize
+	)
+	for d.more(limit) {
+		if bs.BitLength%8 != 0 {
+			return bs, fmt.Errorf("bitstring: unused bits in segment other than the last")
+		}
+		id, n, err := d.decodeIdentifier()
+		if err != nil {
+			return bs, err
+		}
+		if id.Class() != Universal || id.Tag() != BitString.Tag() {
+			return bs, fmt.Errorf("bitstring: unexpected tag %d (class %d) for segment", id.Tag(), id.Class())
+		}
+		if id.Type() == Constructed && d.rules != BER {
+			return bs, d.rulesError(d.offset, "segment should use the primitive form")
+		}
+		d.offset += n
+		z, n, err := d.decodeLength()
+		if err != nil {
+			return bs, err
+		}
+		d.offset += n
+		var seg Bits
+		if id.Type() == Constructed {
+			if err := d.enter(); err != nil {
+				return bs, err
+			}
+			seg, err = d.decodeBitSegments(z)
+			d.exit()
+		} else {
+			if d.rules == CER && (last != cerSegmentSize || z > cerSegmentSize) {
+				return bs, d.rulesError(d.offset, "invalid segment size")
+			}
+			last = z
+			d.offset += z
+			seg, err = d.decodeBitString(d.buf[d.offset-z : d.offset])
+		}
+		if err != nil {
+			return bs, err
+		}
+		bs.Bytes = append(bs.Bytes, seg.Bytes...)
+		bs.BitLength += seg.BitLength
+	}
+	if d.rules == CER && len(bs.Bytes)+1 <= cerSegmentSize {
+		return bs, d.rulesError(d.offset, "bitstring should use the primitive form")
+	}
+	return bs, d.leave(limit)
+}
+
+func encodeBitString(val Bits) ([]byte, error) {
+	size := (val.BitLength + 7) / 8
+	if val.BitLength < 0 || size > len(val.Bytes) {
+		return nil, fmt.Errorf("bitstring: invalid length %d for %d bytes", val.BitLength, len(val.Bytes))
+	}
+	var (
+		b      = make([]byte, size+1)
+		unused = size*8 - val.BitLength
+	)
+	b[0] = byte(unused)
+	copy(b[1:], val.Bytes[:size])
+	if unused > 0 {
+		b[size] &= 0xFF << uint(unused)
+	}
+	return b, nil
+}
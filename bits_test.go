@@ -0,0 +1,172 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBitString(t *testing.T) {
+	t.Run("bits", testBitStringBits)
+	t.Run("encode", testEncodeBitString)
+	t.Run("decode", testDecodeBitString)
+	t.Run("struct", testBitStringStruct)
+	t.Run("cer", testBitStringCER)
+	t.Run("depth", testBitStringDepth)
+}
+
+func testBitStringDepth(t *testing.T) {
+	var buf []byte
+	for i := 0; i < 100000; i++ {
+		buf = append(buf, 0x23, 0x80)
+	}
+	var bs Bits
+	if err := NewDecoder(buf).Decode(&bs); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("depth: depth error expected, got %v", err)
+	}
+	if _, err := NewDecoder(buf).DecodeBitString(); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("depth: depth error expected, got %v", err)
+	}
+}
+
+func testBitStringBits(t *testing.T) {
+	var bs Bits
+	bs.Set(0, true)
+	bs.Set(9, true)
+	bs.Set(3, true)
+	bs.Set(3, false)
+	if bs.BitLength != 10 || !bytes.Equal(bs.Bytes, []byte{0x80, 0x40}) {
+		t.Errorf("bits: unexpected bitstring %x (%d)", bs.Bytes, bs.BitLength)
+	}
+	for i, want := range []int{1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0} {
+		if got := bs.At(i); got != want {
+			t.Errorf("bits: bit %d mismatched! want %d, got %d", i, want, got)
+		}
+	}
+	if got, want := bs.RightAlign(), []byte{0x02, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("bits: right aligned bytes mismatched! want %x, got %x", want, got)
+	}
+}
+
+func testEncodeBitString(t *testing.T) {
+	data := []struct {
+		Input Bits
+		Want  []byte
+	}{
+		{Input: Bits{}, Want: []byte{0x03, 0x01, 0x00}},
+		{Input: Bits{Bytes: []byte{0xff}, BitLength: 8}, Want: []byte{0x03, 0x02, 0x00, 0xff}},
+		{Input: Bits{Bytes: []byte{0x0a, 0x3b, 0x5f, 0x29, 0x1c, 0xd0}, BitLength: 44}, Want: []byte{0x03, 0x07, 0x04, 0x0a, 0x3b, 0x5f, 0x29, 0x1c, 0xd0}},
+		{Input: Bits{Bytes: []byte{0xff, 0xff}, BitLength: 9}, Want: []byte{0x03, 0x03, 0x07, 0xff, 0x80}},
+	}
+	for _, d := range data {
+		var e Encoder
+		if err := e.Encode(d.Input); err != nil {
+			t.Errorf("%x: fail to encode bitstring! %s", d.Input.Bytes, err)
+			continue
+		}
+		if got := e.Bytes(); !bytes.Equal(got, d.Want) {
+			t.Errorf("%x: bytes mismatched! want %x, got %x", d.Input.Bytes, d.Want, got)
+		}
+	}
+	var e Encoder
+	if err := e.EncodeBitString(Bits{Bytes: []byte{0xff}, BitLength: 12}); err == nil {
+		t.Errorf("bitstring: invalid length should be rejected")
+	}
+}
+
+func testDecodeBitString(t *testing.T) {
+	data := []struct {
+		Input []byte
+		Want  Bits
+	}{
+		{Input: []byte{0x03, 0x01, 0x00}, Want: Bits{Bytes: []byte{}}},
+		{Input: []byte{0x03, 0x03, 0x07, 0xff, 0x80}, Want: Bits{Bytes: []byte{0xff, 0x80}, BitLength: 9}},
+		{
+			Input: []byte{0x23, 0x80, 0x03, 0x03, 0x00, 0x0a, 0x3b, 0x03, 0x05, 0x04, 0x5f, 0x29, 0x1c, 0xd0, 0x00, 0x00},
+			Want:  Bits{Bytes: []byte{0x0a, 0x3b, 0x5f, 0x29, 0x1c, 0xd0}, BitLength: 44},
+		},
+	}
+	for _, d := range data {
+		got, err := NewDecoder(d.Input).DecodeBitString()
+		if err != nil {
+			t.Errorf("%x: fail to decode bitstring! %s", d.Input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, d.Want) {
+			t.Errorf("%x: bitstring mismatched! want %+v, got %+v", d.Input, d.Want, got)
+		}
+	}
+	invalid := [][]byte{
+		{0x03, 0x00},
+		{0x03, 0x01, 0x01},
+		{0x03, 0x02, 0x08, 0x00},
+		{0x23, 0x80, 0x03, 0x02, 0x04, 0xf0, 0x03, 0x02, 0x00, 0xff, 0x00, 0x00},
+	}
+	for _, in := range invalid {
+		if _, err := NewDecoder(in).DecodeBitString(); err == nil {
+			t.Errorf("%x: decoding should have failed", in)
+		}
+	}
+	d := NewDecoder([]byte{0x03, 0x02, 0x04, 0xff})
+	d.SetRules(DER)
+	if _, err := d.DecodeBitString(); err == nil {
+		t.Errorf("bitstring: unused bits set should be rejected in DER")
+	}
+}
+
+func testBitStringStruct(t *testing.T) {
+	type Sample struct {
+		Flags Bits
+		Key   []byte `ber:"bitstring"`
+	}
+	var (
+		want = Sample{
+			Flags: Bits{Bytes: []byte{0xa0}, BitLength: 3},
+			Key:   []byte{0xde, 0xad},
+		}
+		got Sample
+		e   Encoder
+	)
+	if err := e.Encode(want); err != nil {
+		t.Errorf("struct: fail to encode! %s", err)
+		return
+	}
+	raw := []byte{0x30, 0x09, 0x03, 0x02, 0x05, 0xa0, 0x03, 0x03, 0x00, 0xde, 0xad}
+	if !bytes.Equal(e.Bytes(), raw) {
+		t.Errorf("struct: bytes mismatched! want %x, got %x", raw, e.Bytes())
+	}
+	if err := NewDecoder(e.Bytes()).Decode(&got); err != nil {
+		t.Errorf("struct: fail to decode! %s", err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("struct: values mismatched! want %+v, got %+v", want, got)
+	}
+}
+
+func testBitStringCER(t *testing.T) {
+	var (
+		want = Bits{Bytes: bytes.Repeat([]byte{0xf0}, 1500), BitLength: 1500*8 - 4}
+		e    = Encoder{rules: CER}
+	)
+	if err := e.EncodeBitString(want); err != nil {
+		t.Errorf("cer: fail to encode! %s", err)
+		return
+	}
+	buf := e.Bytes()
+	if !bytes.Equal(buf[:6], []byte{0x23, 0x80, 0x03, 0x82, 0x03, 0xe8}) {
+		t.Errorf("cer: unexpected header %x", buf[:6])
+		return
+	}
+	d := NewDecoder(buf)
+	d.SetRules(CER)
+	got, err := d.DecodeBitString()
+	if err != nil {
+		t.Errorf("cer: fail to decode! %s", err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cer: bitstring mismatched!")
+	}
+}
@@ -0,0 +1,146 @@
+package ber
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var choices = struct {
+	sync.RWMutex
+	types map[reflect.Type]*choice
+}{
+	types: make(map[reflect.Type]*choice),
+}
+
+// choice gives the alternatives of a CHOICE type.
+type choice struct {
+	idents map[Ident]reflect.Type
+	types  map[reflect.Type]Ident
+}
+
+// lookup gives the type of the alternative encoded with the identifier id.
+func (c *choice) lookup(id Ident) (reflect.Type, bool) {
+	typ, ok := c.idents[choiceKey(id)]
+	return typ, ok
+}
+
+// ident gives the identifier of the alternative of type typ.
+func (c *choice) ident(typ reflect.Type) (Ident, bool) {
+	id, ok := c.types[typ]
+	return id, ok
+}
+
+// named gives the type of the alternative with the given name.
+func (c *choice) named(name string) (reflect.Type, bool) {
+	for typ := range c.types {
+		if choiceName(typ) == name {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+// choiceName gives the name of the alternative of type typ used by the JSON
+// encoding rules: the name of the type without its package.
+func choiceName(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Name()
+}
+
+// RegisterChoice declares that alt is an alternative of the CHOICE represented
+// by an interface type and that it is encoded with the identifier id. iface
+// should be a pointer to the interface type (eg: (*Shape)(nil)) and alt should
+// implement it.
+//
+// The Encoder writes the identifier of the alternative found in an interface
+// value and the Decoder creates the alternative matching the identifier of the
+// element decoded in an interface value. The class and the tag number of id
+// select the alternative; the constructed bit is set for alternatives encoded
+// as a SEQUENCE.
+func RegisterChoice(iface interface{}, id Ident, alt interface{}) {
+	typ := reflect.TypeOf(iface)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("%s is not a pointer to an interface", typ))
+	}
+	typ = typ.Elem()
+	at := reflect.TypeOf(alt)
+	if at == nil || !at.Implements(typ) {
+		panic(fmt.Sprintf("%s does not implement %s", at, typ))
+	}
+	if id.isZero() {
+		panic(fmt.Sprintf("%s: alternative %s without identifier", typ, at))
+	}
+	if typeIdent(at).Type() == Constructed {
+		id = id.Constructed()
+	}
+
+	choices.Lock()
+	defer choices.Unlock()
+	c, ok := choices.types[typ]
+	if !ok {
+		c = &choice{
+			idents: make(map[Ident]reflect.Type),
+			types:  make(map[reflect.Type]Ident),
+		}
+		choices.types[typ] = c
+	}
+	if other, ok := c.idents[choiceKey(id)]; ok && other != at {
+		panic(fmt.Sprintf("%s: %s already used by alternative %s", typ, id, other))
+	}
+	if other, ok := c.types[at]; ok && choiceKey(other) != choiceKey(id) {
+		panic(fmt.Sprintf("%s: alternative %s already registered with %s", typ, at, other))
+	}
+	c.idents[choiceKey(id)] = at
+	c.types[at] = id
+}
+
+func registeredChoice(typ reflect.Type) (*choice, bool) {
+	choices.RLock()
+	defer choices.RUnlock()
+	c, ok := choices.types[typ]
+	return c, ok
+}
+
+// choiceKey gives the identifier id without its constructed bit.
+func choiceKey(id Ident) Ident {
+	return retag(0, id.Class(), id.Tag())
+}
+
+// encodeChoice encodes the alternative held by the interface value val of the
+// CHOICE c with the identifier of the alternative.
+func (e *Encoder) encodeChoice(val reflect.Value, c *choice, tag Ident) error {
+	if !tag.isZero() {
+		return fmt.Errorf("choice: %s can not be tagged implicitly", val.Type())
+	}
+	if val.IsNil() {
+		return fmt.Errorf("choice: %s: no alternative selected", val.Type())
+	}
+	alt := val.Elem()
+	id, ok := c.ident(alt.Type())
+	if !ok {
+		return fmt.Errorf("choice: %s is not an alternative of %s", alt.Type(), val.Type())
+	}
+	return e.encodeValue(alt, id)
+}
+
+// decodeChoice decodes the next element in the alternative of the CHOICE c
+// selected by its identifier and stores it in the interface value val.
+func (d *Decoder) decodeChoice(val reflect.Value, c *choice) error {
+	id, err := d.Peek()
+	if err != nil {
+		return err
+	}
+	typ, ok := c.lookup(id)
+	if !ok {
+		return d.structuralError(d.offset, 0, fmt.Errorf("choice: no alternative of %s for %s", val.Type(), id))
+	}
+	alt := reflect.New(typ).Elem()
+	if err := d.decodeValue(alt); err != nil {
+		return err
+	}
+	val.Set(alt)
+	return nil
+}
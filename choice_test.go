@@ -0,0 +1,135 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type choiceShape interface {
+	area() int
+}
+
+type choiceSquare struct {
+	Side int
+}
+
+func (s choiceSquare) area() int {
+	return s.Side * s.Side
+}
+
+type choiceRect struct {
+	Width, Height int
+}
+
+func (r *choiceRect) area() int {
+	return r.Width * r.Height
+}
+
+type choiceLabel string
+
+func (choiceLabel) area() int {
+	return 0
+}
+
+func init() {
+	RegisterChoice((*choiceShape)(nil), NewPrimitive(0).Context(), choiceSquare{})
+	RegisterChoice((*choiceShape)(nil), NewPrimitive(1).Context(), &choiceRect{})
+	RegisterChoice((*choiceShape)(nil), UTF8String, choiceLabel(""))
+}
+
+type choiceDrawing struct {
+	Main   choiceShape
+	Shapes []choiceShape
+	Extra  choiceShape `ber:"optional"`
+	Count  int
+}
+
+func TestChoice(t *testing.T) {
+	t.Run("encode", testEncodeChoice)
+	t.Run("decode", testDecodeChoice)
+	t.Run("tagged", testTaggedChoice)
+	t.Run("errors", testChoiceErrors)
+}
+
+var choiceBytes = []byte{
+	0x30, 0x19,
+	0xa0, 0x03, 0x02, 0x01, 0x02,
+	0x30, 0x0f,
+	0xa1, 0x06, 0x02, 0x01, 0x03, 0x02, 0x01, 0x04,
+	0x0c, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c,
+	0x02, 0x01, 0x01,
+}
+
+func testEncodeChoice(t *testing.T) {
+	in := choiceDrawing{
+		Main:   choiceSquare{Side: 2},
+		Shapes: []choiceShape{&choiceRect{Width: 3, Height: 4}, choiceLabel("label")},
+		Count:  1,
+	}
+	var e Encoder
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("encode: fail to encode choice! %s", err)
+	}
+	if got := e.Bytes(); !bytes.Equal(got, choiceBytes) {
+		t.Errorf("encode: bytes mismatched! want %x, got %x", choiceBytes, got)
+	}
+}
+
+func testDecodeChoice(t *testing.T) {
+	var out choiceDrawing
+	if err := NewDecoder(choiceBytes).Decode(&out); err != nil {
+		t.Fatalf("decode: fail to decode choice! %s", err)
+	}
+	want := choiceDrawing{
+		Main:   choiceSquare{Side: 2},
+		Shapes: []choiceShape{&choiceRect{Width: 3, Height: 4}, choiceLabel("label")},
+		Count:  1,
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("decode: values mismatched! want %+v, got %+v", want, out)
+	}
+}
+
+func testTaggedChoice(t *testing.T) {
+	type tagged struct {
+		Shape choiceShape `ber:"tag:5,class:2"`
+	}
+	in := tagged{Shape: choiceLabel("a")}
+
+	var e Encoder
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("tagged: fail to encode choice! %s", err)
+	}
+	want := []byte{0x30, 0x05, 0xa5, 0x03, 0x0c, 0x01, 0x61}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("tagged: bytes mismatched! want %x, got %x", want, got)
+	}
+	var out tagged
+	if err := NewDecoder(want).Decode(&out); err != nil {
+		t.Fatalf("tagged: fail to decode choice! %s", err)
+	}
+	if out != in {
+		t.Errorf("tagged: values mismatched! want %+v, got %+v", in, out)
+	}
+}
+
+func testChoiceErrors(t *testing.T) {
+	var e Encoder
+	if err := e.Encode(choiceDrawing{Main: &choiceSquare{}}); err == nil {
+		t.Errorf("errors: unregistered alternative encoded")
+	}
+	var (
+		out choiceDrawing
+		buf = []byte{0x30, 0x05, 0x82, 0x01, 0x00, 0x30, 0x00}
+		se  *StructuralError
+	)
+	err := NewDecoder(buf).Decode(&out)
+	if !errors.As(err, &se) {
+		t.Fatalf("errors: expected *StructuralError, got %T (%v)", err, err)
+	}
+	if want := "choiceDrawing.Main"; se.Path != want {
+		t.Errorf("errors: path mismatched! want %s, got %s", want, se.Path)
+	}
+}
@@ -0,0 +1,210 @@
+// berdump prints the elements of BER encoded data as an indented tree, in the
+// way of openssl asn1parse.
+//
+// Usage:
+//
+//	berdump [-f format] [-x] [-c] [-w width] [file]
+//
+// Data is read from file or from stdin. format is one of auto (default), der,
+// hex, base64 or pem. In auto mode, PEM, hex and base64 inputs are detected and
+// anything else is read as binary.
+//
+// With -x, an annotated hexdump is printed instead of the tree, coloured by
+// depth with -c and with width bytes of contents per line.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/midbel/ber"
+)
+
+func main() {
+	var (
+		format  = flag.String("f", "auto", "input format (auto, der, hex, base64, pem)")
+		hexdump = flag.Bool("x", false, "print an annotated hexdump")
+		color   = flag.Bool("c", false, "colour the hexdump by depth")
+		width   = flag.Int("w", 16, "bytes of contents per line of the hexdump")
+	)
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		r = f
+	}
+	buf, err := readInput(r, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *hexdump {
+		err = ber.Dump(os.Stdout, buf, ber.DumpOptions{Width: *width, Color: *color})
+	} else {
+		err = dump(os.Stdout, buf)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func readInput(r io.Reader, format string) ([]byte, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "der", "ber", "binary":
+		return buf, nil
+	case "hex":
+		return hex.DecodeString(stripSpaces(string(buf)))
+	case "base64":
+		return base64.StdEncoding.DecodeString(stripSpaces(string(buf)))
+	case "pem":
+		b, _ := pem.Decode(buf)
+		if b == nil {
+			return nil, fmt.Errorf("no PEM block found")
+		}
+		return b.Bytes, nil
+	case "auto":
+	default:
+		return nil, fmt.Errorf("%s: unknown input format", format)
+	}
+	if b, _ := pem.Decode(buf); b != nil {
+		return b.Bytes, nil
+	}
+	if str := stripSpaces(string(buf)); str != "" {
+		if b, err := hex.DecodeString(str); err == nil {
+			return b, nil
+		}
+		if b, err := base64.StdEncoding.DecodeString(str); err == nil {
+			return b, nil
+		}
+	}
+	return buf, nil
+}
+
+func stripSpaces(str string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, str)
+}
+
+func dump(w io.Writer, buf []byte) error {
+	it := ber.NewIterator(buf)
+	return dumpElements(w, &it, 0)
+}
+
+func dumpElements(w io.Writer, it *ber.Iterator, depth int) error {
+	for it.Next() {
+		var (
+			id     = it.Ident()
+			raw    = it.Raw()
+			header = it.Header()
+			length = fmt.Sprintf("%4d", len(it.Content()))
+			kind   = "prim"
+		)
+		if it.Indefinite() {
+			length = " inf"
+		}
+		if id.Type() == ber.Constructed {
+			kind = "cons"
+		}
+		fmt.Fprintf(w, "%5d:d=%-2d hl=%d l=%s %s: %s%s", it.Offset(), depth, header, length, kind, strings.Repeat("  ", depth), identName(id))
+		if id.Type() == ber.Primitive {
+			if str := formatValue(raw, it.Content()); str != "" {
+				fmt.Fprintf(w, " :%s", str)
+			}
+		}
+		fmt.Fprintln(w)
+		if id.Type() == ber.Constructed {
+			child := it.Descend()
+			if err := dumpElements(w, &child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
+
+func identName(id ber.Ident) string {
+	switch id.Class() {
+	case ber.Universal:
+		if str := id.Name(); str != "" {
+			return str
+		}
+		return fmt.Sprintf("univ [ %d ]", id.Tag())
+	case ber.Application:
+		return fmt.Sprintf("appl [ %d ]", id.Tag())
+	case ber.Context:
+		return fmt.Sprintf("cont [ %d ]", id.Tag())
+	default:
+		return fmt.Sprintf("priv [ %d ]", id.Tag())
+	}
+}
+
+const maxBytes = 32
+
+// formatValue gives the value of the primitive element raw with the contents
+// content.
+func formatValue(raw ber.Raw, content []byte) string {
+	id, err := raw.Peek()
+	if err != nil || id.Class() != ber.Universal {
+		return formatBytes(content)
+	}
+	var v interface{}
+	if err := ber.NewDecoder(raw).Decode(&v); err != nil {
+		return fmt.Sprintf("<%s> %s", err, formatBytes(content))
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return fmt.Sprint(v)
+	case int64:
+		return fmt.Sprint(v)
+	case *big.Int:
+		return v.String()
+	case float64:
+		return fmt.Sprint(v)
+	case string:
+		return v
+	case ber.OID:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case ber.Bits:
+		return fmt.Sprintf("%d bits %s", v.BitLength, formatBytes(v.Bytes))
+	default:
+		return formatBytes(content)
+	}
+}
+
+func formatBytes(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if len(b) > maxBytes {
+		return fmt.Sprintf("%X...", b[:maxBytes])
+	}
+	return fmt.Sprintf("%X", b)
+}
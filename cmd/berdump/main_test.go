@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadInput(t *testing.T) {
+	want := []byte{0x02, 0x01, 0x05}
+	data := []struct {
+		Input  string
+		Format string
+	}{
+		{Input: "\x02\x01\x05", Format: "der"},
+		{Input: "\x02\x01\x05", Format: "auto"},
+		{Input: "02 01\n05", Format: "auto"},
+		{Input: "AgEF\n", Format: "auto"},
+		{Input: "AgEF", Format: "base64"},
+		{Input: "-----BEGIN DATA-----\nAgEF\n-----END DATA-----\n", Format: "auto"},
+	}
+	for _, d := range data {
+		got, err := readInput(strings.NewReader(d.Input), d.Format)
+		if err != nil {
+			t.Errorf("%q: fail to read input! %s", d.Input, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%q: bytes mismatched! want %x, got %x", d.Input, want, got)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	buf := []byte{
+		0x30, 0x80,
+		0x02, 0x01, 0x05,
+		0xa1, 0x05, 0x0c, 0x03, 0x66, 0x6f, 0x6f,
+		0x06, 0x03, 0x2a, 0x86, 0x48,
+		0x00, 0x00,
+	}
+	want := []string{
+		"    0:d=0  hl=2 l= inf cons: SEQUENCE",
+		"    2:d=1  hl=2 l=   1 prim:   INTEGER :5",
+		"    5:d=1  hl=2 l=   5 cons:   cont [ 1 ]",
+		"    7:d=2  hl=2 l=   3 prim:     UTF8String :foo",
+		"   12:d=1  hl=2 l=   3 prim:   OBJECT IDENTIFIER :1.2.840",
+	}
+	var str strings.Builder
+	if err := dump(&str, buf); err != nil {
+		t.Fatalf("fail to dump! %s", err)
+	}
+	got := strings.Split(strings.TrimRight(str.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("lines mismatched! want %d, got %d\n%s", len(want), len(got), str.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d mismatched!\nwant: %q\ngot:  %q", i, want[i], got[i])
+		}
+	}
+	if err := dump(&str, buf[:10]); err == nil {
+		t.Errorf("truncated input dumped without error")
+	}
+
+	// a definite length in the long form ending with 0x80 is not indefinite
+	buf = append([]byte{0x30, 0x81, 0x80, 0x04, 0x7e}, make([]byte, 126)...)
+	str.Reset()
+	if err := dump(&str, buf); err != nil {
+		t.Fatalf("fail to dump! %s", err)
+	}
+	first, _, _ := strings.Cut(str.String(), "\n")
+	if want := "    0:d=0  hl=3 l= 128 cons: SEQUENCE"; first != want {
+		t.Errorf("line mismatched!\nwant: %q\ngot:  %q", want, first)
+	}
+}
@@ -2,7 +2,9 @@ package ber
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
@@ -13,6 +15,55 @@ type Decoder struct {
 	buf    []byte
 	offset int
 	err    error
+	rules  Rules
+	// identifier of the element being decoded
+	ident Ident
+	// source of the elements of a stream Decoder
+	r io.Reader
+	// identifiers of the elements decoded into the fields of a struct are not
+	// checked
+	lenient bool
+	tagging Tagging
+	// number of constructed elements being decoded around the current one
+	depth int
+}
+
+// maxDepth is the maximum number of constructed elements nested in each other
+// accepted by a Decoder.
+const maxDepth = 256
+
+// enter is called before decoding the elements of a constructed element. It
+// fails when the elements are nested too deeply.
+func (d *Decoder) enter() error {
+	if d.depth >= maxDepth {
+		return fmt.Errorf("%w (more than %d levels)", ErrTooDeep, maxDepth)
+	}
+	d.depth++
+	return nil
+}
+
+// exit is called after decoding the elements of a constructed element.
+func (d *Decoder) exit() {
+	d.depth--
+}
+
+// TruncatedError describes an input that ends before the end of the element
+// being decoded. It matches io.ErrUnexpectedEOF with errors.Is.
+type TruncatedError struct {
+	// offset in the input of the bytes that are missing
+	Offset int
+	// number of bytes expected from Offset
+	Want int
+	// number of bytes available from Offset
+	Have int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("unexpected end of input at offset %d: want %d bytes, have %d", e.Offset, e.Want, e.Have)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return io.ErrUnexpectedEOF
 }
 
 func NewDecoder(buf []byte) *Decoder {
@@ -21,11 +72,49 @@ func NewDecoder(buf []byte) *Decoder {
 	}
 }
 
-func (d *Decoder) Peek() (Ident, error) {
+// NewStreamDecoder creates a Decoder reading its input from r. The bytes of the
+// next element are read from r only when all the buffered bytes have been
+// decoded, and exactly the bytes of this element are read. io.EOF is returned
+// when r has no more elements.
+func NewStreamDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// SetRules selects the encoding rules that the input should conform to. With
+// DER or CER, every construct that is not allowed by the selected rules is
+// rejected with a *RulesError.
+func (d *Decoder) SetRules(r Rules) {
+	d.rules = r
+}
+
+// SetTagging selects how the tags of the fields of a struct are applied when
+// their field tags say neither explicit nor implicit. It should match the tag
+// default of the ASN.1 module defining the values.
+func (d *Decoder) SetTagging(t Tagging) {
+	d.tagging = t
+}
+
+// SetLenient disables the verification of the identifiers of the elements
+// decoded into the fields of a struct. It should be used only with peers that
+// do not use the identifiers expected for the fields.
+func (d *Decoder) SetLenient(lenient bool) {
+	d.lenient = lenient
+}
+
+func (d *Decoder) Peek() (_ Ident, err error) {
+	if err := d.fill(); err != nil {
+		return 0, err
+	}
+	defer d.wrapError(&err, d.offset)
+	if d.Empty() {
+		return 0, d.truncated(d.offset, 1)
+	}
 	id, _, err := decodeIdentifier(d.buf[d.offset:])
 	return id, err
 }
 
+// Need gives the number of bytes of the next element. For an element using the
+// indefinite form, Need returns 0 until all its bytes are available.
 func (d *Decoder) Need() int {
 	offset := d.offset
 	_, n, err := decodeIdentifier(d.buf[offset:])
@@ -37,6 +126,13 @@ func (d *Decoder) Need() int {
 	if err != nil {
 		return 0
 	}
+	if size == indefinite {
+		z, err := measure(d.buf[d.offset:])
+		if err != nil {
+			return 0
+		}
+		return z
+	}
 	return size + x + n
 }
 
@@ -77,76 +173,111 @@ func (d *Decoder) Can() bool {
 	if d.Empty() {
 		return false
 	}
-	offset := d.offset
-	_, n, err := decodeIdentifier(d.buf[offset:])
-	if err != nil {
-		return false
-	}
-	offset += n
-	if offset >= len(d.buf) {
-		return false
+	_, err := measure(d.buf[d.offset:])
+	return err == nil
+}
+
+func (d *Decoder) Skip() (err error) {
+	if err := d.fill(); err != nil {
+		return err
 	}
-	size, n, err := decodeLength(d.buf[offset:])
+	defer d.wrapError(&err, d.offset)
+	_, n, err := d.decodeIdentifier()
 	if err != nil {
-		return false
+		return err
 	}
-	offset += n + size
-	return offset <= len(d.buf)
-}
-
-func (d *Decoder) Skip() error {
-	_, n, err := decodeIdentifier(d.buf[d.offset:])
+	d.offset += n
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
-	if err == nil {
-		d.offset += n + size
+	if size != indefinite {
+		d.offset += size
+		return nil
 	}
-	return err
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.exit()
+	for d.more(indefinite) {
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+	return d.leave(indefinite)
 }
 
-func (d *Decoder) Decode(value interface{}) error {
+// Decode decodes the next element into the value pointed to by value.
+//
+// An element decoded into an empty interface is given the Go type matching its
+// identifier:
+//
+//	BOOLEAN                    bool
+//	INTEGER, ENUMERATED        int64 or *big.Int if it overflows an int64
+//	BIT STRING                 Bits
+//	OCTET STRING               []byte
+//	NULL                       nil
+//	OBJECT IDENTIFIER          OID
+//	REAL                       float64
+//	character strings          string
+//	UTCTime, GeneralizedTime   time.Time
+//	SEQUENCE, SET              []interface{}
+//	other                      Tagged
+func (d *Decoder) Decode(value interface{}) (err error) {
+	if err := d.fill(); err != nil {
+		return err
+	}
+	defer func(offset int) {
+		d.wrapError(&err, offset)
+		err = rootPath(err, reflect.TypeOf(value))
+	}(d.offset)
 	if u, ok := value.(Unmarshaler); ok {
 		return d.decodeUnmarshaler(u)
 	}
-	var err error
 	switch val := value.(type) {
 	case *string:
 		*val, err = d.DecodeString()
 	case *bool:
 		*val, err = d.DecodeBool()
 	case *int:
-		x, e := d.DecodeInt()
+		x, e := d.decodeIntSize(strconv.IntSize)
 		*val, err = int(x), e
 	case *int8:
-		x, e := d.DecodeInt()
+		x, e := d.decodeIntSize(8)
 		*val, err = int8(x), e
 	case *int16:
-		x, e := d.DecodeInt()
+		x, e := d.decodeIntSize(16)
 		*val, err = int16(x), e
 	case *int32:
-		x, e := d.DecodeInt()
+		x, e := d.decodeIntSize(32)
 		*val, err = int32(x), e
 	case *int64:
 		*val, err = d.DecodeInt()
 	case *uint:
-		x, e := d.DecodeUint()
+		x, e := d.decodeUintSize(strconv.IntSize)
 		*val, err = uint(x), e
 	case *uint8:
-		x, e := d.DecodeUint()
+		x, e := d.decodeUintSize(8)
 		*val, err = uint8(x), e
 	case *uint16:
-		x, e := d.DecodeUint()
+		x, e := d.decodeUintSize(16)
 		*val, err = uint16(x), e
 	case *uint32:
-		x, e := d.DecodeUint()
+		x, e := d.decodeUintSize(32)
 		*val, err = uint32(x), e
 	case *uint64:
 		*val, err = d.DecodeUint()
+	case *big.Int:
+		x, e := d.DecodeBigInt()
+		if e == nil {
+			val.Set(x)
+		}
+		err = e
 	case *time.Time:
 		*val, err = d.DecodeTime()
+	case *Bits:
+		*val, err = d.DecodeBitString()
 	case *Raw:
 		*val, err = d.decodeRaw()
 	default:
@@ -155,21 +286,40 @@ func (d *Decoder) Decode(value interface{}) error {
 	return err
 }
 
-func (d *Decoder) DecodeTagged() (Ident, int, error) {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+// DecodeTagged decodes the identifier and the length of the next element. The
+// returned length is -1 if the element uses the indefinite form: its contents
+// then end with end-of-contents octets that can be consumed with
+// DecodeEndOfContents.
+func (d *Decoder) DecodeTagged() (_ Ident, _ int, err error) {
+	if err := d.fill(); err != nil {
+		return 0, 0, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return id, 0, err
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err == nil {
 		d.offset += n
 	}
-	return id, size, nil
+	return id, size, err
 }
 
-func (d *Decoder) DecodeNull() error {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+// DecodeEndOfContents consumes the end-of-contents octets terminating a value
+// using the indefinite form. It reports false without consuming anything if
+// the next element is not the end-of-contents.
+func (d *Decoder) DecodeEndOfContents() bool {
+	return d.leave(indefinite) == nil
+}
+
+func (d *Decoder) DecodeNull() (err error) {
+	if err := d.fill(); err != nil {
+		return err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return err
 	}
@@ -177,7 +327,7 @@ func (d *Decoder) DecodeNull() error {
 		return fmt.Errorf("null: %w", ErrPrimitive)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
@@ -188,8 +338,12 @@ func (d *Decoder) DecodeNull() error {
 	return nil
 }
 
-func (d *Decoder) DecodeBool() (bool, error) {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+func (d *Decoder) DecodeBool() (_ bool, err error) {
+	if err := d.fill(); err != nil {
+		return false, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return false, err
 	}
@@ -197,7 +351,7 @@ func (d *Decoder) DecodeBool() (bool, error) {
 		return false, fmt.Errorf("bool: %w", ErrPrimitive)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return false, err
 	}
@@ -205,6 +359,9 @@ func (d *Decoder) DecodeBool() (bool, error) {
 		return false, fmt.Errorf("bool: value should have length 1 (got, %d)", size)
 	}
 	d.offset += n
+	if err := d.checkBool(d.buf[d.offset]); err != nil {
+		return false, err
+	}
 	d.offset += size
 	return d.buf[d.offset-size] > 0x00, nil
 }
@@ -213,8 +370,12 @@ func (d *Decoder) DecodeEnumerated() (int64, error) {
 	return d.DecodeInt()
 }
 
-func (d *Decoder) DecodeInt() (int64, error) {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+func (d *Decoder) DecodeInt() (_ int64, err error) {
+	if err := d.fill(); err != nil {
+		return 0, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return 0, err
 	}
@@ -222,16 +383,39 @@ func (d *Decoder) DecodeInt() (int64, error) {
 		return 0, fmt.Errorf("int: %w", ErrPrimitive)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return 0, err
 	}
-	d.offset += size + n
+	d.offset += n
+	if err := d.checkInt(d.buf[d.offset : d.offset+size]); err != nil {
+		return 0, err
+	}
+	// the element is consumed even if its value overflows so that the
+	// decoding can go on with the next element
+	d.offset += size
+	if size > 8 {
+		return 0, fmt.Errorf("int: %w", ErrOverflow)
+	}
 	return decodeInt(d.buf[d.offset-size:d.offset], true), nil
 }
 
-func (d *Decoder) DecodeUint() (uint64, error) {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+// decodeIntSize decodes an INTEGER that should fit in a signed integer of the
+// given number of bits.
+func (d *Decoder) decodeIntSize(bits int) (int64, error) {
+	x, err := d.DecodeInt()
+	if err == nil && bits < 64 && (x < -1<<uint(bits-1) || x > 1<<uint(bits-1)-1) {
+		err = fmt.Errorf("int: %w", ErrOverflow)
+	}
+	return x, err
+}
+
+func (d *Decoder) DecodeUint() (_ uint64, err error) {
+	if err := d.fill(); err != nil {
+		return 0, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return 0, err
 	}
@@ -239,18 +423,44 @@ func (d *Decoder) DecodeUint() (uint64, error) {
 		return 0, fmt.Errorf("uint: %w", ErrPrimitive)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return 0, err
 	}
-	d.offset += size + n
+	d.offset += n
+	if err := d.checkInt(d.buf[d.offset : d.offset+size]); err != nil {
+		return 0, err
+	}
+	b := d.buf[d.offset : d.offset+size]
+	d.offset += size
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		return 0, fmt.Errorf("uint: %w", ErrOverflow)
+	}
+	if len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) > 8 {
+		return 0, fmt.Errorf("uint: %w", ErrOverflow)
+	}
+	return uint64(decodeInt(b, false)), nil
+}
 
-	j := decodeInt(d.buf[d.offset-size:d.offset], false)
-	return uint64(j), nil
+// decodeUintSize decodes an INTEGER that should fit in an unsigned integer of
+// the given number of bits.
+func (d *Decoder) decodeUintSize(bits int) (uint64, error) {
+	x, err := d.DecodeUint()
+	if err == nil && bits < 64 && x > 1<<uint(bits)-1 {
+		err = fmt.Errorf("uint: %w", ErrOverflow)
+	}
+	return x, err
 }
 
-func (d *Decoder) DecodeFloat() (float64, error) {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+func (d *Decoder) DecodeFloat() (_ float64, err error) {
+	if err := d.fill(); err != nil {
+		return 0, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return 0, err
 	}
@@ -258,14 +468,19 @@ func (d *Decoder) DecodeFloat() (float64, error) {
 		return 0, fmt.Errorf("float: %w", ErrPrimitive)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return 0, err
 	}
 	if size == 0 {
+		d.offset += n
 		return 0, nil
 	}
-	d.offset += size + n
+	d.offset += n
+	if err := d.checkFloat(d.buf[d.offset : d.offset+size]); err != nil {
+		return 0, err
+	}
+	d.offset += size
 	var (
 		str  = d.buf[d.offset-size+1 : d.offset]
 		info = d.buf[d.offset-size]
@@ -280,43 +495,75 @@ func (d *Decoder) DecodeFloat() (float64, error) {
 	default:
 		return 0, fmt.Errorf("invalid float encoding: %x (%x)", info, str)
 	}
-	return 0, nil
 }
 
-func (d *Decoder) DecodeBytes() ([]byte, error) {
-	_, n, err := decodeIdentifier(d.buf[d.offset:])
+func (d *Decoder) DecodeBytes() (_ []byte, err error) {
+	if err := d.fill(); err != nil {
+		return nil, err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return nil, err
 	}
+	if err := d.checkPrimitive(id); err != nil {
+		return nil, err
+	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return nil, err
 	}
-	d.offset += size + n
+	d.offset += n
+	if id.Type() == Constructed {
+		return d.decodeSegments(id, size)
+	}
+	if err := d.checkSegment(size); err != nil {
+		return nil, err
+	}
+	d.offset += size
 	if size == 0 {
 		return nil, nil
 	}
 	return d.buf[d.offset-size : d.offset], nil
 }
 
-func (d *Decoder) DecodeString() (string, error) {
-	_, n, err := decodeIdentifier(d.buf[d.offset:])
+func (d *Decoder) DecodeString() (_ string, err error) {
+	if err := d.fill(); err != nil {
+		return "", err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return "", err
 	}
+	if err := d.checkPrimitive(id); err != nil {
+		return "", err
+	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return "", err
 	}
-	d.offset += size + n
+	d.offset += n
+	if id.Type() == Constructed {
+		str, err := d.decodeSegments(id, size)
+		return string(str), err
+	}
+	if err := d.checkSegment(size); err != nil {
+		return "", err
+	}
+	d.offset += size
 	str := d.buf[d.offset-size : d.offset]
 	return string(str), nil
 }
 
-func (d *Decoder) DecodeOID() (string, error) {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+func (d *Decoder) DecodeOID() (_ string, err error) {
+	if err := d.fill(); err != nil {
+		return "", err
+	}
+	defer d.wrapError(&err, d.offset)
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return "", err
 	}
@@ -324,7 +571,7 @@ func (d *Decoder) DecodeOID() (string, error) {
 		return "", fmt.Errorf("oid: %w", ErrPrimitive)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return "", err
 	}
@@ -352,17 +599,25 @@ func (d *Decoder) DecodeOID() (string, error) {
 	return oid, nil
 }
 
-func (d *Decoder) DecodeTime() (time.Time, error) {
+func (d *Decoder) DecodeTime() (_ time.Time, err error) {
+	if err := d.fill(); err != nil {
+		return time.Time{}, err
+	}
+	defer d.wrapError(&err, d.offset)
 	var t time.Time
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return t, err
 	}
 	if id.Type() != Primitive {
 		return t, fmt.Errorf("time: %w", ErrPrimitive)
 	}
+	kind := id
+	if kind.Class() != Universal {
+		kind = GeneralizedTime
+	}
 	var pattern string
-	switch id.Tag() {
+	switch kind.Tag() {
 	case Int.Tag():
 		i, err := d.DecodeInt()
 		if err != nil {
@@ -370,18 +625,22 @@ func (d *Decoder) DecodeTime() (time.Time, error) {
 		}
 		return time.Unix(i, 0), nil
 	case UniversalTime.Tag():
-		pattern = patUniversTime
+		pattern = patUniversTimeParse
 	case GeneralizedTime.Tag():
-		pattern = patGeneralTime
+		pattern = patGeneralTimeParse
 	default:
-		return t, fmt.Errorf("unsupported tag for time")
+		return t, d.structuralError(d.offset, GeneralizedTime, fmt.Errorf("unsupported tag for time"))
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return t, err
 	}
-	d.offset += size + n
+	d.offset += n
+	if err := d.checkTime(d.buf[d.offset : d.offset+size]); err != nil {
+		return t, err
+	}
+	d.offset += size
 	str := d.buf[d.offset-size : d.offset]
 	t, err = time.Parse(pattern, string(str))
 	if err == nil {
@@ -397,34 +656,45 @@ var (
 
 func (d *Decoder) decodeRaw() (Raw, error) {
 	offset := d.offset
-	_, n, err := decodeIdentifier(d.buf[d.offset:])
-	if err != nil {
-		return nil, err
-	}
-	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
-	if err != nil {
+	if err := d.Skip(); err != nil {
 		return nil, err
 	}
-	d.offset += n + size
 	return Raw(d.buf[offset:d.offset]), nil
 }
 
 func (d *Decoder) decodeUnmarshaler(u Unmarshaler) error {
-	_, n, err := decodeIdentifier(d.buf[d.offset:])
+	_, n, err := d.decodeIdentifier()
 	if err != nil {
 		return err
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
-	d.offset += n + size
-	return u.Unmarshal(d.buf[d.offset-size : d.offset])
+	d.offset += n
+	if size != indefinite {
+		d.offset += size
+		return u.Unmarshal(d.buf[d.offset-size : d.offset])
+	}
+	offset := d.offset
+	for d.more(indefinite) {
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+	end := d.offset
+	if err := d.leave(indefinite); err != nil {
+		return err
+	}
+	return u.Unmarshal(d.buf[offset:end])
 }
 
 func (d *Decoder) decodeValue(val reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.exit()
 	if val.CanInterface() && val.Type().Implements(unmarshaltype) {
 		return d.decodeUnmarshaler(val.Interface().(Unmarshaler))
 	}
@@ -434,6 +704,9 @@ func (d *Decoder) decodeValue(val reflect.Value) error {
 			return d.decodeUnmarshaler(pv.Interface().(Unmarshaler))
 		}
 	}
+	if names, ok := registeredNamedBits(val.Type()); ok {
+		return d.decodeNamedBits(val, names)
+	}
 	if val.Type() == rawtype {
 		raw, err := d.decodeRaw()
 		if err == nil {
@@ -450,10 +723,43 @@ func (d *Decoder) decodeValue(val reflect.Value) error {
 			}
 			return err
 		}
+		if bitstringtype == val.Type() {
+			bs, err := d.DecodeBitString()
+			if err == nil {
+				val.Set(reflect.ValueOf(bs))
+			}
+			return err
+		}
+		if taggedtype == val.Type() {
+			id, err := d.Peek()
+			if err != nil {
+				return err
+			}
+			t, err := d.decodeTagged(id)
+			if err == nil {
+				val.Set(reflect.ValueOf(t))
+			}
+			return err
+		}
+		if bigtype == val.Type() {
+			x, err := d.DecodeBigInt()
+			if err == nil {
+				val.Addr().Interface().(*big.Int).Set(x)
+			}
+			return err
+		}
 		return d.decodeStruct(val)
 	case reflect.Array:
 		return d.decodeArray(val)
 	case reflect.Slice:
+		if id, _ := d.Peek(); val.Type() == bytestype && id.Class() == Universal && id.Tag() == BitString.Tag() {
+			bs, err := d.DecodeBitString()
+			if err != nil {
+				return err
+			}
+			val.SetBytes(bs.Bytes)
+			break
+		}
 		if val.Type() == bytestype {
 			bs, err := d.DecodeBytes()
 			if err != nil {
@@ -465,10 +771,32 @@ func (d *Decoder) decodeValue(val reflect.Value) error {
 		return d.decodeSlice(val)
 	case reflect.Map:
 		return d.decodeMap(val)
+	case reflect.Interface:
+		if c, ok := registeredChoice(val.Type()); ok {
+			return d.decodeChoice(val, c)
+		}
+		if val.NumMethod() == 0 {
+			return d.decodeInterface(val)
+		}
+		return d.structuralError(d.offset, 0, fmt.Errorf("no alternative registered for %s", val.Type()))
 	case reflect.Ptr:
+		if id, _ := d.Peek(); id.Class() == Universal && id.Tag() == Null.Tag() && val.Type().Elem() != rawtype {
+			val.Set(reflect.Zero(val.Type()))
+			return d.DecodeNull()
+		}
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
 		return d.decodeValue(val.Elem())
-	case reflect.Interface:
 	case reflect.String:
+		if val.Type() == oidtype {
+			v, err := d.DecodeOID()
+			if err != nil {
+				return err
+			}
+			val.SetString(v)
+			break
+		}
 		v, err := d.DecodeString()
 		if err != nil {
 			return err
@@ -487,19 +815,19 @@ func (d *Decoder) decodeValue(val reflect.Value) error {
 		}
 		val.SetFloat(v)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		v, err := d.DecodeInt()
+		v, err := d.decodeIntSize(val.Type().Bits())
 		if err != nil {
 			return err
 		}
 		val.SetInt(v)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		v, err := d.DecodeUint()
+		v, err := d.decodeUintSize(val.Type().Bits())
 		if err != nil {
 			return err
 		}
 		val.SetUint(v)
 	default:
-		return fmt.Errorf("element can not be decoded into %s", k)
+		return d.structuralError(d.offset, 0, fmt.Errorf("element can not be decoded into %s", k))
 	}
 	return nil
 }
@@ -507,7 +835,7 @@ func (d *Decoder) decodeValue(val reflect.Value) error {
 var identtype = reflect.TypeOf(Ident(0))
 
 func (d *Decoder) decodeStruct(val reflect.Value) error {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return err
 	}
@@ -515,43 +843,319 @@ func (d *Decoder) decodeStruct(val reflect.Value) error {
 		return fmt.Errorf("struct: %w", ErrConstructed)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
 	d.offset += n
-	if size == 0 {
-		return nil
+	if id.isSet() && !d.lenient {
+		return d.decodeSet(val, id, d.limit(size))
 	}
 	var (
-		limit = d.offset + size
+		limit = d.limit(size)
 		typ   = val.Type()
+		tags  = structTags(typ, d.tagging)
+		prev  element
 	)
-	for i := 0; i < val.NumField() && d.offset < limit; i++ {
-		f := val.Field(i)
-		if !f.CanSet() {
+	for i := 0; i < val.NumField(); i++ {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || !f.CanSet() {
+			continue
+		}
+		if ft.err != nil {
+			return prefixPath(d.structuralError(d.offset, 0, ft.err), sf.Name)
+		}
+		if ft.ident {
+			f.Set(reflect.ValueOf(id))
 			continue
 		}
-		if tf := typ.Field(i); f.Type() == identtype {
-			if tf.Name == "Id" || tf.Tag.Get("ber") == "id" {
-				f.Set(reflect.ValueOf(id))
-				continue
+		if !d.more(limit) && !ft.opts.canBeAbsent() && !ft.omit {
+			return prefixPath(d.structuralError(d.offset, 0, fmt.Errorf("missing element %s", sf.Name)), sf.Name)
+		}
+		if !d.more(limit) || (ft.opts.canBeAbsent() && !d.matchField(f, sf, ft)) {
+			if ft.opts.hasDef {
+				def, err := defaultValue(f.Type(), ft.opts.def)
+				if err != nil {
+					return prefixPath(d.structuralError(d.offset, 0, err), sf.Name)
+				}
+				f.Set(def)
 			}
-		} else if tf.Tag.Get("ber") == "-" {
 			continue
 		}
-		if err := d.decodeValue(f); err != nil {
-			return err
+		if id.isSet() {
+			curr, err := d.checkSetOrder(prev, compareTag)
+			if err != nil {
+				return err
+			}
+			prev = curr
+		}
+		offset := d.offset
+		if err := d.decodeField(f, sf, ft); err != nil {
+			d.wrapError(&err, offset)
+			return prefixPath(err, sf.Name)
 		}
-		if d.offset > limit {
+		if limit != indefinite && d.offset > limit {
 			return fmt.Errorf("struct: too many bytes consumed to decode value")
 		}
 	}
+	return d.leave(limit)
+}
+
+// decodeSet decodes the elements of a SET in the fields of val. Since the order
+// of the elements of a SET is not the order of the fields, each element is
+// decoded in the field whose identifier matches its own.
+func (d *Decoder) decodeSet(val reflect.Value, id Ident, limit int) error {
+	var (
+		typ  = val.Type()
+		tags = structTags(typ, d.tagging)
+		done = make([]bool, val.NumField())
+		prev element
+	)
+	for i := range done {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || !f.CanSet() {
+			done[i] = true
+			continue
+		}
+		if ft.err != nil {
+			return prefixPath(d.structuralError(d.offset, 0, ft.err), sf.Name)
+		}
+		if ft.ident {
+			f.Set(reflect.ValueOf(id))
+			done[i] = true
+		}
+	}
+	for d.more(limit) {
+		curr, err := d.checkSetOrder(prev, compareTag)
+		if err != nil {
+			return err
+		}
+		prev = curr
+		i := d.setField(val, tags, done)
+		if i < 0 {
+			got, _ := d.Peek()
+			return d.structuralError(d.offset, 0, fmt.Errorf("set: no field for element %s", got))
+		}
+		done[i] = true
+		var (
+			f      = val.Field(i)
+			sf     = typ.Field(i)
+			offset = d.offset
+		)
+		if err := d.decodeField(f, sf, tags[i]); err != nil {
+			d.wrapError(&err, offset)
+			return prefixPath(err, sf.Name)
+		}
+		if limit != indefinite && d.offset > limit {
+			return fmt.Errorf("set: too many bytes consumed to decode value")
+		}
+	}
+	for i := range done {
+		ft := tags[i]
+		if done[i] || ft.omit || ft.opts.optional {
+			continue
+		}
+		if !ft.opts.hasDef {
+			err := fmt.Errorf("missing element %s", typ.Field(i).Name)
+			return prefixPath(d.structuralError(d.offset, 0, err), typ.Field(i).Name)
+		}
+		def, err := defaultValue(val.Field(i).Type(), ft.opts.def)
+		if err != nil {
+			return prefixPath(d.structuralError(d.offset, 0, err), typ.Field(i).Name)
+		}
+		val.Field(i).Set(def)
+	}
+	return d.leave(limit)
+}
+
+// setField gives the index of the field not decoded yet that the next element
+// of a SET should be decoded in or -1 if there is none. Fields accepting any
+// element are only selected when no other field matches.
+func (d *Decoder) setField(val reflect.Value, tags []fieldTag, done []bool) int {
+	var (
+		typ = val.Type()
+		any = -1
+	)
+	for i := range done {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if done[i] || !d.matchField(f, sf, ft) {
+			continue
+		}
+		if ft.outer.isZero() && fieldIdent(f.Type(), sf, ft).isZero() {
+			if any < 0 {
+				any = i
+			}
+			continue
+		}
+		return i
+	}
+	return any
+}
+
+func (d *Decoder) decodeField(f reflect.Value, sf reflect.StructField, ft fieldTag) error {
+	if !ft.outer.isZero() {
+		return d.decodeExplicit(f, sf, ft)
+	}
+	if !d.lenient {
+		if err := d.checkField(f, sf, ft); err != nil {
+			return err
+		}
+	}
+	if names, ok := namedBitsForField(sf); ok {
+		return d.decodeNamedBits(f, names)
+	}
+	return d.decodeValue(f)
+}
+
+// decodeExplicit decodes the value of a field wrapped in a constructed element
+// with the tag of the field.
+func (d *Decoder) decodeExplicit(f reflect.Value, sf reflect.StructField, ft fieldTag) error {
+	id, n, err := d.decodeIdentifier()
+	if err != nil {
+		return err
+	}
+	if !d.lenient && !matchIdent(ft.outer, id) {
+		return d.structuralError(d.offset, ft.outer, fmt.Errorf("%s: unexpected identifier %s", sf.Name, id))
+	}
+	if id.Type() != Constructed {
+		return fmt.Errorf("explicit: %w", ErrConstructed)
+	}
+	d.offset += n
+	size, n, err := d.decodeLength()
+	if err != nil {
+		return err
+	}
+	d.offset += n
+	limit := d.limit(size)
+	ft.outer = 0
+	if err := d.decodeField(f, sf, ft); err != nil {
+		return err
+	}
+	if limit != indefinite && d.offset != limit {
+		return fmt.Errorf("explicit: %d bytes remained after value", limit-d.offset)
+	}
+	return d.leave(limit)
+}
+
+// checkField verifies that the identifier of the next element matches the one
+// written by the Encoder for the field sf. Identifiers set by the field tag
+// should match exactly while identifiers derived from the type of the field
+// accept the universal tags of the same family (eg: any string type for a
+// string).
+func (d *Decoder) checkField(f reflect.Value, sf reflect.StructField, ft fieldTag) error {
+	got, err := d.Peek()
+	if err != nil {
+		// the error is reported by the decoding of the field
+		return nil
+	}
+	want := fieldIdent(f.Type(), sf, ft)
+	if !acceptIdent(f.Type(), want, got) {
+		return d.structuralError(d.offset, want, fmt.Errorf("%s: unexpected identifier %s", sf.Name, got))
+	}
 	return nil
 }
 
+// matchField reports whether the next element can be decoded into the field sf.
+func (d *Decoder) matchField(f reflect.Value, sf reflect.StructField, ft fieldTag) bool {
+	got, err := d.Peek()
+	if err != nil {
+		return false
+	}
+	if !ft.outer.isZero() {
+		return matchIdent(ft.outer, got)
+	}
+	return acceptIdent(f.Type(), fieldIdent(f.Type(), sf, ft), got)
+}
+
+// fieldIdent gives the identifier used by the Encoder for the value of the
+// field sf of type typ. It is zero if any element can be decoded in the field.
+func fieldIdent(typ reflect.Type, sf reflect.StructField, ft fieldTag) Ident {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == rawtype || typ == taggedtype || typ.Kind() == reflect.Interface || typ.Implements(unmarshaltype) || reflect.PtrTo(typ).Implements(unmarshaltype) {
+		return 0
+	}
+	want := ft.id
+	if _, ok := namedBitsForField(sf); ok && want.Class() == Universal {
+		want = BitString
+	}
+	if want.isZero() {
+		want = typeIdent(typ)
+	}
+	return want
+}
+
+// acceptIdent reports whether an element with the identifier got can be
+// decoded into a value of type typ expecting the identifier want.
+func acceptIdent(typ reflect.Type, want, got Ident) bool {
+	if c, ok := registeredChoice(typ); ok && want.isZero() {
+		_, ok = c.lookup(got)
+		return ok
+	}
+	if want.isZero() {
+		return true
+	}
+	if typ.Kind() == reflect.Ptr {
+		if got.Class() == Universal && got.Tag() == Null.Tag() {
+			return true
+		}
+		typ = typ.Elem()
+	}
+	if typ == bytestype && got == BitString {
+		return true
+	}
+	return matchIdent(want, got)
+}
+
+// matchIdent reports whether an element with identifier got can be decoded
+// into a value expecting the identifier want.
+func matchIdent(want, got Ident) bool {
+	if want.Class() != got.Class() {
+		return false
+	}
+	if want.Tag() == got.Tag() {
+		return true
+	}
+	if want.Class() != Universal {
+		return false
+	}
+	family := func(i Ident) int {
+		switch i.Tag() {
+		case Int.Tag(), Enumerated.Tag():
+			return 1
+		case UniversalTime.Tag(), GeneralizedTime.Tag():
+			return 2
+		case Sequence.Tag(), Set.Tag():
+			return 3
+		case OctetString.Tag(), UTF8String.Tag(), PrintableString.Tag(), IA5String.Tag():
+			return 4
+		case 0x12, 0x14, 0x15, 0x19, 0x1a, 0x1b, 0x1c, 0x1e:
+			// Numeric, Teletex, Videotex, Graphic, Visible, General,
+			// Universal and BMP strings
+			return 4
+		default:
+			return 0
+		}
+	}
+	f := family(want)
+	return f != 0 && f == family(got)
+}
+
 func (d *Decoder) decodeMap(val reflect.Value) error {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return err
 	}
@@ -559,7 +1163,7 @@ func (d *Decoder) decodeMap(val reflect.Value) error {
 		return fmt.Errorf("map: %w", ErrConstructed)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
@@ -568,30 +1172,68 @@ func (d *Decoder) decodeMap(val reflect.Value) error {
 		return nil
 	}
 	var (
-		limit = d.offset + size
+		limit = d.limit(size)
 		mp    = reflect.MakeMap(val.Type())
 		typ   = mp.Type()
 	)
-	for d.offset < limit {
-		// TODO: element of map should be decoded as sequence type
+	for d.more(limit) {
 		k, v := reflect.New(typ.Key()).Elem(), reflect.New(typ.Elem()).Elem()
-		if err := d.decodeValue(k); err != nil {
+		if err := d.decodeMapEntry(k, v); err != nil {
 			return err
 		}
-		if err := d.decodeValue(v); err != nil {
-			return err
-		}
-		if d.offset > limit {
+		if limit != indefinite && d.offset > limit {
 			return fmt.Errorf("map: too many bytes consumed to decode value")
 		}
 		mp.SetMapIndex(k, v)
 	}
 	val.Set(mp)
-	return nil
+	return d.leave(limit)
+}
+
+// decodeMapEntry decodes the key and the value of an entry of a map given as a
+// SEQUENCE of its key and its value. With BER, an entry whose key is not a
+// SEQUENCE can also be given as its key followed by its value.
+func (d *Decoder) decodeMapEntry(k, v reflect.Value) error {
+	offset := d.offset
+	id, n, err := d.decodeIdentifier()
+	if err != nil {
+		return err
+	}
+	limit := indefinite
+	if id == Sequence || d.rules != BER {
+		if id != Sequence {
+			return d.structuralError(offset, Sequence, fmt.Errorf("map: entry should be a sequence"))
+		}
+		d.offset += n
+		size, n, err := d.decodeLength()
+		if err != nil {
+			return err
+		}
+		d.offset += n
+		limit = d.limit(size)
+	}
+
+	offset = d.offset
+	if err := d.decodeValue(k); err != nil {
+		d.wrapError(&err, offset)
+		return err
+	}
+	offset = d.offset
+	if err := d.decodeValue(v); err != nil {
+		d.wrapError(&err, offset)
+		return prefixPath(err, keyPath(k))
+	}
+	if id != Sequence {
+		return nil
+	}
+	if limit != indefinite && d.offset != limit {
+		return fmt.Errorf("map: entry should only have a key and a value")
+	}
+	return d.leave(limit)
 }
 
 func (d *Decoder) decodeSlice(val reflect.Value) error {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return err
 	}
@@ -599,7 +1241,7 @@ func (d *Decoder) decodeSlice(val reflect.Value) error {
 		return fmt.Errorf("slice: %w", ErrConstructed)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
@@ -608,14 +1250,26 @@ func (d *Decoder) decodeSlice(val reflect.Value) error {
 		return nil
 	}
 	var (
-		limit = d.offset + size
+		limit = d.limit(size)
 		typ   = val.Type()
 		slice = reflect.MakeSlice(typ, 0, val.Len())
+		prev  element
 	)
-	for d.offset < limit {
-		e := reflect.New(typ.Elem()).Elem()
+	for d.more(limit) {
+		if id.isSet() {
+			curr, err := d.checkSetOrder(prev, compareEncoding)
+			if err != nil {
+				return err
+			}
+			prev = curr
+		}
+		var (
+			e      = reflect.New(typ.Elem()).Elem()
+			offset = d.offset
+		)
 		if err := d.decodeValue(e); err != nil {
-			return err
+			d.wrapError(&err, offset)
+			return prefixPath(err, indexPath(slice.Len()))
 		}
 		// if d.offset > limit {
 		// 	return fmt.Errorf("slice: too many bytes consumed to decode value")
@@ -626,11 +1280,11 @@ func (d *Decoder) decodeSlice(val reflect.Value) error {
 		slice = reflect.AppendSlice(val, slice.Slice(n, slice.Len()))
 		val.Set(slice)
 	}
-	return nil
+	return d.leave(limit)
 }
 
 func (d *Decoder) decodeArray(val reflect.Value) error {
-	id, n, err := decodeIdentifier(d.buf[d.offset:])
+	id, n, err := d.decodeIdentifier()
 	if err != nil {
 		return err
 	}
@@ -638,7 +1292,7 @@ func (d *Decoder) decodeArray(val reflect.Value) error {
 		return fmt.Errorf("array: %w", ErrConstructed)
 	}
 	d.offset += n
-	size, n, err := decodeLength(d.buf[d.offset:])
+	size, n, err := d.decodeLength()
 	if err != nil {
 		return err
 	}
@@ -646,21 +1300,231 @@ func (d *Decoder) decodeArray(val reflect.Value) error {
 	if size == 0 {
 		return nil
 	}
-	limit := d.offset + size
+	var (
+		limit = d.limit(size)
+		prev  element
+	)
 	for i := 0; i < val.Len(); i++ {
-		if d.offset >= limit {
+		if !d.more(limit) {
 			break
 		}
+		if id.isSet() {
+			curr, err := d.checkSetOrder(prev, compareEncoding)
+			if err != nil {
+				return err
+			}
+			prev = curr
+		}
+		offset := d.offset
 		if err := d.decodeValue(val.Index(i)); err != nil {
-			return err
+			d.wrapError(&err, offset)
+			return prefixPath(err, indexPath(i))
 		}
 	}
-	if d.offset < limit {
-		return fmt.Errorf("array: undecoded values remained! array too short")
+	if d.more(limit) {
+		return d.structuralError(d.offset, 0, fmt.Errorf("array: undecoded values remained! array too short"))
+	}
+	return d.leave(limit)
+}
+
+func (d *Decoder) decodeIdentifier() (Ident, int, error) {
+	if d.Empty() {
+		return 0, 0, d.truncated(d.offset, 1)
+	}
+	id, n, err := decodeIdentifier(d.buf[d.offset:])
+	if last := d.buf[d.offset+n-1]; err == nil && ((n == 1 && last&0x1F == 0x1F) || (n > 1 && last&0x80 != 0)) {
+		err = d.truncated(d.offset, n+1)
+	}
+	if err == nil {
+		d.ident = id
+		err = d.checkIdentifier(d.buf[d.offset : d.offset+n])
+	}
+	return id, n, err
+}
+
+// decodeLength decodes the length octets at the current offset. It ensures that
+// the contents of an element using the definite form are available in the
+// buffer so that callers can slice them without further checks.
+func (d *Decoder) decodeLength() (int, int, error) {
+	if d.Empty() {
+		return 0, 0, d.truncated(d.offset, 1)
+	}
+	if c := int(d.buf[d.offset]); c > 0x80 && c&0x7F >= d.Len() {
+		return 0, 0, d.truncated(d.offset, c&0x7F+1)
+	}
+	size, n, err := decodeLength(d.buf[d.offset:])
+	if err != nil {
+		return size, n, err
+	}
+	if size == indefinite && d.ident.Type() == Primitive {
+		return size, n, fmt.Errorf("indefinite length for primitive encoding")
+	}
+	if size != indefinite && size > d.Len()-n {
+		return size, n, d.truncated(d.offset+n, size)
+	}
+	return size, n, d.checkLength(d.buf[d.offset:d.offset+n], size)
+}
+
+// truncated gives the error returned when want bytes are needed from offset
+// but the buffer ends before.
+func (d *Decoder) truncated(offset, want int) error {
+	have := len(d.buf) - offset
+	if have < 0 {
+		have = 0
+	}
+	return &TruncatedError{
+		Offset: offset,
+		Want:   want,
+		Have:   have,
+	}
+}
+
+// limit gives the offset where the contents of a constructed value of the
+// given size ends or indefinite if its length uses the indefinite form.
+func (d *Decoder) limit(size int) int {
+	if size == indefinite {
+		return indefinite
+	}
+	return d.offset + size
+}
+
+// more reports whether the contents of the constructed value ending at limit
+// have more elements to decode.
+func (d *Decoder) more(limit int) bool {
+	if limit == indefinite {
+		return d.offset < len(d.buf) && !isEOC(d.buf[d.offset:])
+	}
+	return d.offset < limit
+}
+
+// leave consumes the end-of-contents octets terminating a constructed value
+// encoded with the indefinite form.
+func (d *Decoder) leave(limit int) error {
+	if limit != indefinite {
+		return nil
+	}
+	if d.Len() < 2 {
+		return d.truncated(d.offset, 2)
 	}
+	if !isEOC(d.buf[d.offset:]) {
+		return fmt.Errorf("end-of-contents expected")
+	}
+	d.offset += 2
 	return nil
 }
 
+// decodeSegments decodes the contents of a string encoded with the constructed
+// form. When the contents are not a sequence of segments, they are returned
+// as is: this is the form produced by the Encoder when a string is given a
+// constructed identifier.
+func (d *Decoder) decodeSegments(id Ident, size int) ([]byte, error) {
+	offset := d.offset
+	buf, err := d.decodeSegmentList(id, size)
+	if err != nil && d.rules == BER && size != indefinite {
+		d.offset = offset + size
+		return d.buf[offset:d.offset], nil
+	}
+	if err == nil && d.rules == CER && len(buf) <= cerSegmentSize {
+		err = d.rulesError(offset, "string should use the primitive form")
+	}
+	return buf, err
+}
+
+func (d *Decoder) decodeSegmentList(parent Ident, size int) ([]byte, error) {
+	var (
+		limit = d.limit(size)
+		last  = cerSegmentSize
+		buf   []byte
+	)
+	for d.more(limit) {
+		id, n, err := d.decodeIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		if id.Class() != Universal || (id.Tag() != OctetString.Tag() && id.Tag() != parent.Tag()) {
+			return nil, fmt.Errorf("segment: unexpected tag %d (class %d)", id.Tag(), id.Class())
+		}
+		if id.Type() == Constructed && d.rules != BER {
+			return nil, d.rulesError(d.offset, "segment should use the primitive form")
+		}
+		d.offset += n
+		size, n, err := d.decodeLength()
+		if err != nil {
+			return nil, err
+		}
+		d.offset += n
+		if size != indefinite && (d.offset+size > len(d.buf) || (limit != indefinite && d.offset+size > limit)) {
+			return nil, fmt.Errorf("segment too long")
+		}
+		if id.Type() == Constructed {
+			if err := d.enter(); err != nil {
+				return nil, err
+			}
+			b, err := d.decodeSegmentList(id, size)
+			d.exit()
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+			continue
+		}
+		if d.rules == CER && (last != cerSegmentSize || size == 0 || size > cerSegmentSize) {
+			return nil, d.rulesError(d.offset, "invalid segment size")
+		}
+		last = size
+		buf = append(buf, d.buf[d.offset:d.offset+size]...)
+		d.offset += size
+	}
+	return buf, d.leave(limit)
+}
+
+const indefinite = -1
+
+func isEOC(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0 && b[1] == 0
+}
+
+// measure gives the number of bytes of the element at the start of b,
+// identifier and length octets included. Elements using the indefinite form
+// are measured up to and including their end-of-contents octets.
+func measure(b []byte) (int, error) {
+	return measureDepth(b, 0)
+}
+
+func measureDepth(b []byte, depth int) (int, error) {
+	if depth > maxDepth {
+		return 0, fmt.Errorf("%w (more than %d levels)", ErrTooDeep, maxDepth)
+	}
+	_, n, err := decodeIdentifier(b)
+	if err != nil {
+		return 0, err
+	}
+	size, x, err := decodeLength(b[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += x
+	if size != indefinite {
+		if size > len(b)-n {
+			return 0, fmt.Errorf("element too short")
+		}
+		return n + size, nil
+	}
+	for {
+		if n >= len(b) {
+			return 0, fmt.Errorf("end-of-contents expected")
+		}
+		if isEOC(b[n:]) {
+			return n + 2, nil
+		}
+		z, err := measureDepth(b[n:], depth+1)
+		if err != nil {
+			return 0, err
+		}
+		n += z
+	}
+}
+
 func decodeIdentifier(b []byte) (Ident, int, error) {
 	if len(b) == 0 {
 		return 0, 0, fmt.Errorf("identifier should have at least 1 byte")
@@ -722,6 +1586,9 @@ func decodeBinaryFloat(info byte, str []byte) (float64, error) {
 	} else {
 		size = 4
 	}
+	if len(str) < size || len(str)-size > 8 {
+		return 0, fmt.Errorf("invalid binary float encoding: %x (%x)", info, str)
+	}
 	m := float64(decodeInt(str[size:], true))
 	e := float64(decodeInt(str[:size], true))
 	return sign * m * math.Pow(2, e), nil
@@ -738,13 +1605,22 @@ func decodeLength(b []byte) (int, int, error) {
 	if b[0]>>7 == 0 {
 		return int(b[0] & 0x7F), 1, nil
 	}
+	if b[0] == 0x80 {
+		return indefinite, 1, nil
+	}
 	var (
 		i int64
 		n int
 		c = int(b[0] & 0x7F)
 	)
 	n++
-	for j := 0; j < c && j < len(b); j++ {
+	if c >= len(b) {
+		return 0, 0, fmt.Errorf("length should have at least %d bytes", c+1)
+	}
+	for j := 0; j < c; j++ {
+		if i > math.MaxInt32>>8 {
+			return 0, 0, fmt.Errorf("length too large")
+		}
 		i = (i << 8) | int64(b[j+1])
 		n++
 	}
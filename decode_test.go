@@ -1,6 +1,8 @@
 package ber
 
 import (
+	"errors"
+	"io"
 	"math"
 	"reflect"
 	"testing"
@@ -19,6 +21,85 @@ func TestDecoder(t *testing.T) {
 	t.Run("map", testDecodeMap)
 	t.Run("slice", testDecodeSlice)
 	t.Run("array", testDecodeArray)
+	t.Run("indefinite", testDecodeIndefinite)
+	t.Run("truncated", testDecodeTruncated)
+	t.Run("tags", testDecodeTags)
+	t.Run("optional", testDecodeOptional)
+	t.Run("missing", testDecodeMissing)
+}
+
+func testDecodeIndefinite(t *testing.T) {
+	type Inner struct {
+		Name string
+		List []int
+	}
+	type Sample struct {
+		Id    int
+		Inner Inner
+		Raw   Raw
+		Map   map[string]int
+		Arr   [2]bool
+	}
+	var (
+		input = []byte{
+			0x30, 0x80,
+			0x02, 0x01, 0x2a,
+			0x30, 0x80, // inner
+			0x24, 0x80, 0x04, 0x02, 'f', 'o', 0x04, 0x01, 'o', 0x00, 0x00,
+			0x30, 0x80, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02, 0x00, 0x00,
+			0x00, 0x00,
+			0x30, 0x80, 0x30, 0x80, 0x00, 0x00, 0x00, 0x00, // raw
+			0x31, 0x80, 0x0c, 0x01, 'a', 0x02, 0x01, 0x01, 0x00, 0x00,
+			0x30, 0x80, 0x01, 0x01, 0xff, 0x01, 0x01, 0x00, 0x00, 0x00,
+			0x00, 0x00,
+		}
+		want = Sample{
+			Id: 42,
+			Inner: Inner{
+				Name: "foo",
+				List: []int{1, 2},
+			},
+			Raw: Raw{0x30, 0x80, 0x30, 0x80, 0x00, 0x00, 0x00, 0x00},
+			Map: map[string]int{"a": 1},
+			Arr: [2]bool{true, false},
+		}
+		got Sample
+		d   = NewDecoder(append(input, 0x05, 0x00))
+	)
+	if !d.Can() {
+		t.Errorf("indefinite: complete element not detected")
+		return
+	}
+	if n := d.Need(); n != len(input) {
+		t.Errorf("indefinite: wrong size! want %d, got %d", len(input), n)
+	}
+	if err := d.Decode(&got); err != nil {
+		t.Errorf("indefinite: fail to decode! %s", err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("indefinite: values mismatched! want %+v, got %+v", want, got)
+	}
+	if err := d.DecodeNull(); err != nil {
+		t.Errorf("indefinite: fail to decode trailing value! %s", err)
+	}
+
+	d.Reset(input)
+	if err := d.Skip(); err != nil {
+		t.Errorf("indefinite: fail to skip! %s", err)
+		return
+	}
+	if !d.Empty() {
+		t.Errorf("indefinite: %d bytes remaining after skip", d.Len())
+	}
+
+	d.Reset(input[:len(input)-1])
+	if d.Can() {
+		t.Errorf("indefinite: missing end-of-contents not detected")
+	}
+	if err := d.Decode(&got); err == nil {
+		t.Errorf("indefinite: missing end-of-contents should be rejected")
+	}
 }
 
 func encodeValue(val interface{}) ([]byte, error) {
@@ -344,3 +425,174 @@ func testDecodeTime(t *testing.T) {
 		}
 	}
 }
+
+func testDecodeTruncated(t *testing.T) {
+	data := []struct {
+		Input  []byte
+		Offset int
+	}{
+		{Input: []byte{}, Offset: 0},
+		{Input: []byte{0x30}, Offset: 1},
+		{Input: []byte{0x30, 0x05, 0x04, 0x01}, Offset: 2},
+		{Input: []byte{0x30, 0x82, 0x01}, Offset: 1},
+		{Input: []byte{0x1f, 0x81}, Offset: 0},
+		{Input: []byte{0x30, 0x06, 0x04, 0x04, 0x61}, Offset: 2},
+		{Input: []byte{0x30, 0x80, 0x04, 0x01, 0x61}, Offset: 5},
+		{Input: []byte{0x30, 0x80, 0x04, 0x01, 0x61, 0x00}, Offset: 6},
+	}
+	for _, d := range data {
+		var (
+			list []string
+			err  = NewDecoder(d.Input).Decode(&list)
+			te   *TruncatedError
+		)
+		if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.As(err, &te) {
+			t.Errorf("%x: expected truncated error, got %v", d.Input, err)
+			continue
+		}
+		if te.Offset != d.Offset {
+			t.Errorf("%x: offset mismatched! want %d, got %d", d.Input, d.Offset, te.Offset)
+		}
+	}
+	if _, err := NewDecoder(nil).Peek(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("peek: expected truncated error on empty buffer, got %v", err)
+	}
+}
+
+func testDecodeTags(t *testing.T) {
+	type Sample struct {
+		Name  string
+		Count int `ber:"class:0x2,tag:0x1"`
+		Ref   *int64
+		Bits  Bits     `ber:"tag:0x2,class:0x2"`
+		Items []string `ber:"set"`
+	}
+	var (
+		want = Sample{
+			Name:  "ber",
+			Count: 42,
+			Bits:  Bits{Bytes: []byte{0x80}, BitLength: 1},
+			Items: []string{"foo"},
+		}
+		got Sample
+	)
+	input, err := encodeValue(want)
+	if err != nil {
+		t.Fatalf("tags: fail to encode value %+v! %s", want, err)
+	}
+	if err := NewDecoder(input).Decode(&got); err != nil {
+		t.Fatalf("tags: fail to decode! %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tags: struct mismatched! want %+v, got %+v", want, got)
+	}
+
+	type Simple struct {
+		Name  string
+		Count int
+	}
+	// PrintableString for Name, UTF8String for Count
+	input = []byte{0x30, 0x08, 0x13, 0x01, 0x61, 0x0c, 0x03, 0x31, 0x32, 0x33}
+	var (
+		s  Simple
+		se *StructuralError
+	)
+	err = NewDecoder(input).Decode(&s)
+	if !errors.As(err, &se) {
+		t.Fatalf("tags: expected structural error, got %v", err)
+	}
+	if se.Expected != Int || se.Ident != UTF8String || se.Path != "Simple.Count" {
+		t.Errorf("tags: unexpected error %s", err)
+	}
+	d := NewDecoder(input)
+	d.SetLenient(true)
+	if err := d.Decode(&s); err != nil {
+		t.Errorf("tags: lenient decoder should accept mismatched identifiers! %s", err)
+	}
+}
+
+func testDecodeMissing(t *testing.T) {
+	type Sample struct {
+		Version int `ber:"class:0x2,tag:0,default:1"`
+		Name    string
+		Count   int
+		Extra   *string `ber:"optional"`
+	}
+	data := []struct {
+		Input []byte
+		Path  string
+	}{
+		{Input: []byte{0x30, 0x00}, Path: "Sample.Name"},
+		{Input: []byte{0x30, 0x05, 0x0c, 0x03, 'b', 'e', 'r'}, Path: "Sample.Count"},
+		{Input: []byte{0x31, 0x03, 0x02, 0x01, 0x01}, Path: "Sample.Name"},
+	}
+	for _, d := range data {
+		var (
+			got Sample
+			err = NewDecoder(d.Input).Decode(&got)
+			se  *StructuralError
+		)
+		if !errors.As(err, &se) {
+			t.Errorf("%x: expected *StructuralError, got %T (%v)", d.Input, err, err)
+			continue
+		}
+		if se.Path != d.Path {
+			t.Errorf("%x: path mismatched! want %s, got %s", d.Input, d.Path, se.Path)
+		}
+	}
+}
+
+func testDecodeOptional(t *testing.T) {
+	type Sample struct {
+		Version int   `ber:"class:0x2,tag:0,default:1"`
+		Serial  int64 `ber:"class:0x2,tag:1,optional"`
+		Name    string
+		Ratio   float64 `ber:"default:0.5"`
+		Extra   *string `ber:"optional"`
+	}
+	data := []struct {
+		Input []byte
+		Want  Sample
+	}{
+		{
+			Input: []byte{0x30, 0x05, 0x0c, 0x03, 'b', 'e', 'r'},
+			Want:  Sample{Version: 1, Name: "ber", Ratio: 0.5},
+		},
+		{
+			Input: []byte{0x30, 0x08, 0x80, 0x01, 0x03, 0x0c, 0x03, 'b', 'e', 'r'},
+			Want:  Sample{Version: 3, Name: "ber", Ratio: 0.5},
+		},
+		{
+			Input: []byte{0x30, 0x08, 0x81, 0x01, 0x07, 0x0c, 0x03, 'b', 'e', 'r'},
+			Want:  Sample{Version: 1, Serial: 7, Name: "ber", Ratio: 0.5},
+		},
+		{
+			Input: []byte{0x30, 0x0a, 0x0c, 0x03, 'b', 'e', 'r', 0x09, 0x03, 0x80, 0xfe, 0x01},
+			Want:  Sample{Version: 1, Name: "ber", Ratio: 0.25},
+		},
+	}
+	for _, d := range data {
+		var got Sample
+		if err := NewDecoder(d.Input).Decode(&got); err != nil {
+			t.Errorf("%x: fail to decode! %s", d.Input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, d.Want) {
+			t.Errorf("%x: struct mismatched! want %+v, got %+v", d.Input, d.Want, got)
+		}
+	}
+
+	extra := "extra"
+	in := Sample{Version: 2, Name: "ber", Ratio: 1, Extra: &extra}
+	buf, err := encodeValue(in)
+	if err != nil {
+		t.Fatalf("optional: fail to encode %+v! %s", in, err)
+	}
+	var got Sample
+	if err := NewDecoder(buf).Decode(&got); err != nil {
+		t.Fatalf("optional: fail to decode! %s", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("optional: struct mismatched! want %+v, got %+v", in, got)
+	}
+}
@@ -0,0 +1,162 @@
+package ber
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpOptions controls the output of Dump.
+type DumpOptions struct {
+	// number of bytes of contents written on each line (16 if zero)
+	Width int
+	// colour the bytes and the annotations of each element with ANSI escape
+	// sequences selected by its depth
+	Color bool
+}
+
+var depthColors = []string{
+	"\x1b[36m",
+	"\x1b[33m",
+	"\x1b[32m",
+	"\x1b[35m",
+	"\x1b[34m",
+	"\x1b[31m",
+}
+
+const resetColor = "\x1b[0m"
+
+// Dump writes an annotated hexdump of the elements of buf to w. Each line gives
+// an offset, the octets of the identifier, of the length or of a part of the
+// contents of an element indented by its depth and what these octets are. With
+// a Width of 4:
+//
+//	000000  30                  SEQUENCE [UNIVERSAL 16] constructed
+//	000001  03                  length 3
+//	000002    02                INTEGER [UNIVERSAL 2]
+//	000003    01                length 1
+//	000004      05              contents
+//
+// The elements decoded before an invalid one are written before the error is
+// returned.
+func Dump(w io.Writer, buf []byte, opts DumpOptions) error {
+	if opts.Width <= 0 {
+		opts.Width = 16
+	}
+	dd := dumper{
+		w:    w,
+		d:    Decoder{buf: buf},
+		opts: opts,
+	}
+	return dd.dump(0, len(buf), 0)
+}
+
+type dumper struct {
+	w    io.Writer
+	d    Decoder
+	opts DumpOptions
+}
+
+func (dd *dumper) dump(offset, limit, depth int) error {
+	if depth > maxDepth {
+		err := fmt.Errorf("%w (more than %d levels)", ErrTooDeep, maxDepth)
+		dd.d.wrapError(&err, offset)
+		return err
+	}
+	for offset < limit {
+		s, err := dd.d.span(offset)
+		if err != nil {
+			return err
+		}
+		if s.end > limit {
+			err = fmt.Errorf("element longer than its parent")
+			dd.d.wrapError(&err, offset)
+			return err
+		}
+		_, n, _ := decodeIdentifier(dd.d.buf[offset:])
+		var (
+			eoc = s.limit != s.end
+			str = fmt.Sprintf("length %d", s.limit-s.content)
+		)
+		if eoc {
+			str = "length indefinite"
+		}
+		if err := dd.line(offset, dd.d.buf[offset:offset+n], depth, identLabel(s.id)); err != nil {
+			return err
+		}
+		if err := dd.line(offset+n, dd.d.buf[offset+n:s.content], depth, str); err != nil {
+			return err
+		}
+		if s.id.Type() == Constructed {
+			if err := dd.dump(s.content, s.limit, depth+1); err != nil {
+				return err
+			}
+		} else {
+			for i := s.content; i < s.limit; i += dd.opts.Width {
+				var (
+					end  = i + dd.opts.Width
+					note string
+				)
+				if end > s.limit {
+					end = s.limit
+				}
+				if i == s.content {
+					note = "contents"
+				}
+				if err := dd.line(i, dd.d.buf[i:end], depth+1, note); err != nil {
+					return err
+				}
+			}
+		}
+		if eoc {
+			if err := dd.line(s.limit, dd.d.buf[s.limit:s.end], depth+1, "end-of-contents"); err != nil {
+				return err
+			}
+		}
+		offset = s.end
+	}
+	return nil
+}
+
+func (dd *dumper) line(offset int, b []byte, depth int, note string) error {
+	var (
+		str   strings.Builder
+		width = 3*dd.opts.Width + 8
+	)
+	str.WriteString(strings.Repeat("  ", depth))
+	for i, c := range b {
+		if i > 0 {
+			str.WriteByte(' ')
+		}
+		fmt.Fprintf(&str, "%02x", c)
+	}
+	hex := str.String()
+	if note == "" {
+		width = 0
+	}
+	if n := width - len(hex); n > 0 {
+		hex += strings.Repeat(" ", n)
+	} else if note != "" {
+		hex += " "
+	}
+	if dd.opts.Color {
+		color := depthColors[depth%len(depthColors)]
+		hex = color + hex + resetColor
+		if note != "" {
+			note = color + note + resetColor
+		}
+	}
+	_, err := fmt.Fprintf(dd.w, "%06x  %s%s\n", offset, hex, note)
+	return err
+}
+
+func identLabel(id Ident) string {
+	str := id.String()
+	if name := id.Name(); name != "" {
+		str = name + " " + str
+	}
+	if id.Type() == Constructed {
+		str += " constructed"
+	}
+	return str
+}
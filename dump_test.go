@@ -0,0 +1,48 @@
+package ber
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	buf := []byte{
+		0x30, 0x80,
+		0x02, 0x01, 0x05,
+		0x04, 0x06, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+		0x00, 0x00,
+	}
+	want := []string{
+		"000000  30                  SEQUENCE [UNIVERSAL 16] constructed",
+		"000001  80                  length indefinite",
+		"000002    02                INTEGER [UNIVERSAL 2]",
+		"000003    01                length 1",
+		"000004      05              contents",
+		"000005    04                OCTET STRING [UNIVERSAL 4]",
+		"000006    06                length 6",
+		"000007      01 02 03 04     contents",
+		"00000b      05 06",
+		"00000d    00 00             end-of-contents",
+	}
+	var str strings.Builder
+	if err := Dump(&str, buf, DumpOptions{Width: 4}); err != nil {
+		t.Fatalf("fail to dump! %s", err)
+	}
+	got := strings.Split(strings.TrimRight(str.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("lines mismatched! want %d, got %d\n%s", len(want), len(got), str.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d mismatched!\nwant: %q\ngot:  %q", i, want[i], got[i])
+		}
+	}
+
+	str.Reset()
+	if err := Dump(&str, []byte{0x30, 0x05, 0x02, 0x01, 0x05, 0x04, 0x06, 0x01}, DumpOptions{Color: true}); err == nil {
+		t.Errorf("truncated input dumped without error")
+	}
+	if !strings.Contains(str.String(), depthColors[0]) || !strings.Contains(str.String(), resetColor) {
+		t.Errorf("colours not found in %q", str.String())
+	}
+}
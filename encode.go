@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
 	"math/bits"
 	"reflect"
 	"strconv"
@@ -16,11 +18,48 @@ import (
 var (
 	ErrPrimitive   = errors.New("encoding shall be primitive")
 	ErrConstructed = errors.New("encoding shall be constructed")
+	ErrOverflow    = errors.New("value overflows target type")
+	ErrTooDeep     = errors.New("elements nested too deeply")
 )
 
 type Encoder struct {
-	err error
-	buf []byte
+	err   error
+	buf   []byte
+	rules Rules
+	// number of values opened with EncodeIndefinite not yet closed
+	depth int
+	// destination of the elements of a stream Encoder
+	w io.Writer
+	// error returned by w
+	werr error
+	// tagging applied to the fields of structs
+	tagging Tagging
+}
+
+// NewStreamEncoder creates an Encoder writing each element to w as soon as its
+// encoding is complete. Since nothing is kept in its buffer, Bytes, As,
+// AsSequence and AsSet are useless with such an Encoder.
+func NewStreamEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetRules selects the encoding rules used by the Encoder. With DER and CER,
+// the Encoder produces the canonical encodings defined in X.690 and returns an
+// error for values that have no canonical form. The entries of maps are then
+// sorted by their encoding.
+func (e *Encoder) SetRules(r Rules) {
+	e.rules = r
+}
+
+// SetTagging selects how the tags of the fields of a struct are applied when
+// their field tags say neither explicit nor implicit. It should match the tag
+// default of the ASN.1 module defining the values.
+func (e *Encoder) SetTagging(t Tagging) {
+	e.tagging = t
+}
+
+func (e *Encoder) child() Encoder {
+	return Encoder{rules: e.rules, tagging: e.tagging}
 }
 
 func (e *Encoder) AsSequence() ([]byte, error) {
@@ -28,6 +67,11 @@ func (e *Encoder) AsSequence() ([]byte, error) {
 }
 
 func (e *Encoder) AsSet() ([]byte, error) {
+	if e.rules != BER {
+		if err := e.sortElements(compareSet, false); err != nil {
+			return nil, err
+		}
+	}
 	return e.encodeConstructed(Set)
 }
 
@@ -41,6 +85,56 @@ func (e *Encoder) Bytes() []byte {
 	return buf
 }
 
+// WriteTo writes the bytes encoded so far to w and removes them from the buffer
+// of the Encoder. Combined with EncodeIndefinite, it allows to output large
+// constructed values without keeping them in memory.
+func (e *Encoder) WriteTo(w io.Writer) (int64, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := w.Write(e.buf)
+	e.buf = e.buf[:copy(e.buf, e.buf[n:])]
+	return int64(n), err
+}
+
+// EncodeIndefinite opens a constructed value using the indefinite form. The
+// values encoded afterwards are the elements of this value until
+// EncodeEndOfContents is called.
+func (e *Encoder) EncodeIndefinite(id Ident) error {
+	if e.err != nil {
+		return e.err
+	}
+	if id.isZero() {
+		id = Sequence
+	}
+	if e.rules == DER {
+		return e.rulesError("indefinite length not allowed")
+	}
+	id = id.Constructed()
+	b, err := encodeIdentifier(id.Class(), id.Type(), id.Tag())
+	if err != nil {
+		e.err = err
+		return e.err
+	}
+	e.buf = append(e.buf, b...)
+	e.buf = append(e.buf, 0x80)
+	e.depth++
+	return e.flush()
+}
+
+// EncodeEndOfContents closes the last value opened with EncodeIndefinite.
+func (e *Encoder) EncodeEndOfContents() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.depth == 0 {
+		return encodeError(0, fmt.Errorf("end-of-contents: no indefinite value opened"))
+	}
+	e.buf = append(e.buf, 0x00, 0x00)
+	e.depth--
+	return e.flush()
+}
+
 func (e *Encoder) Encode(val interface{}) error {
 	return e.EncodeWithIdent(val, 0)
 }
@@ -49,6 +143,13 @@ func (e *Encoder) EncodeWithIdent(val interface{}, tag Ident) error {
 	if e.err != nil {
 		return e.err
 	}
+	if err := e.encode(val, tag); err != nil {
+		e.err = rootPath(e.wrapError(err, tag), reflect.TypeOf(val))
+	}
+	return e.err
+}
+
+func (e *Encoder) encode(val interface{}, tag Ident) error {
 	if m, ok := val.(Marshaler); ok {
 		buf, err := m.Marshal()
 		if err != nil {
@@ -56,7 +157,7 @@ func (e *Encoder) EncodeWithIdent(val interface{}, tag Ident) error {
 			return e.err
 		}
 		e.buf = append(e.buf, buf...)
-		return e.err
+		return e.flush()
 	}
 	if val == nil {
 		return e.EncodeNullWithIdent(tag)
@@ -100,6 +201,14 @@ func (e *Encoder) EncodeWithIdent(val interface{}, tag Ident) error {
 		e.err = e.EncodeUintWithIdent(uint64(val), tag)
 	case time.Time:
 		e.err = e.EncodeTimeWithIdent(val, tag)
+	case Bits:
+		e.err = e.EncodeBitStringWithIdent(val, tag)
+	case *big.Int:
+		e.err = e.EncodeBigIntWithIdent(val, tag)
+	case big.Int:
+		e.err = e.EncodeBigIntWithIdent(&val, tag)
+	case Tagged:
+		e.err = e.encodeTagged(val, tag)
 	}
 	return e.err
 }
@@ -109,7 +218,7 @@ func (e *Encoder) EncodeChild(fn func(*Encoder) error) error {
 }
 
 func (e *Encoder) EncodeChildWithIdent(id Ident, fn func(*Encoder) error) error {
-	var ex Encoder
+	ex := e.child()
 	if err := fn(&ex); err != nil {
 		return err
 	}
@@ -125,7 +234,7 @@ func (e *Encoder) EncodeNullWithIdent(tag Ident) error {
 		tag = Null
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("null: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("null: %w", ErrPrimitive))
 	}
 	return e.encodeBytes(nil, tag)
 }
@@ -139,7 +248,7 @@ func (e *Encoder) EncodeBoolWithIdent(val bool, tag Ident) error {
 		tag = Bool
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("bool: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("bool: %w", ErrPrimitive))
 	}
 	var b byte
 	if val {
@@ -157,7 +266,7 @@ func (e *Encoder) EncodeEnumeratedWithIdent(val int64, tag Ident) error {
 		tag = Enumerated
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("enumerated: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("enumerated: %w", ErrPrimitive))
 	}
 	b := encodeInt(val)
 	return e.encodeBytes(b, tag)
@@ -172,7 +281,7 @@ func (e *Encoder) EncodeIntWithIdent(val int64, tag Ident) error {
 		tag = Int
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("int: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("int: %w", ErrPrimitive))
 	}
 	b := encodeInt(val)
 	return e.encodeBytes(b, tag)
@@ -187,7 +296,7 @@ func (e *Encoder) EncodeUintWithIdent(val uint64, tag Ident) error {
 		tag = Int
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("uint: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("uint: %w", ErrPrimitive))
 	}
 	b := encodeUint(val, false)
 	return e.encodeBytes(b, tag)
@@ -226,13 +335,19 @@ func (e *Encoder) EncodeStringWithIdent(val string, tag Ident) error {
 		tag = OctetString
 	}
 	if tag.Tag() == UTF8String.Tag() && !utf8.ValidString(val) {
-		return fmt.Errorf("%s: invalid utf8 string", val)
+		return encodeError(tag, fmt.Errorf("%s: invalid utf8 string", val))
 	} else if tag.Tag() == PrintableString.Tag() && !ValidPrintableString(val) {
-		return fmt.Errorf("%s: invalid printable string", val)
+		return encodeError(tag, fmt.Errorf("%s: invalid printable string", val))
 	} else if tag.Tag() == IA5String.Tag() && !ValidIA5String(val) {
-		return fmt.Errorf("%s: invalid IA5 string", val)
+		return encodeError(tag, fmt.Errorf("%s: invalid IA5 string", val))
 	} else {
 
+	}
+	if e.rules != BER && tag.Type() == Constructed {
+		return encodeError(tag, fmt.Errorf("string: %w", ErrPrimitive))
+	}
+	if e.rules == CER && len(val) > cerSegmentSize {
+		return e.encodeSegments([]byte(val), tag)
 	}
 	return e.encodeBytes([]byte(val), tag)
 }
@@ -245,6 +360,12 @@ func (e *Encoder) EncodeBytesWithIdent(val []byte, tag Ident) error {
 	if tag.isZero() {
 		tag = OctetString
 	}
+	if e.rules != BER && tag.Type() == Constructed {
+		return encodeError(tag, fmt.Errorf("bytes: %w", ErrPrimitive))
+	}
+	if e.rules == CER && len(val) > cerSegmentSize {
+		return e.encodeSegments(val, tag)
+	}
 	return e.encodeBytes(val, tag)
 }
 
@@ -261,21 +382,35 @@ func (e *Encoder) EncodeTimeWithIdent(val time.Time, tag Ident) error {
 	if tag.isZero() {
 		tag = GeneralizedTime
 	}
-	switch tag.Tag() {
+	// time values with a non universal tag are encoded as GeneralizedTime
+	kind := tag
+	if kind.Class() != Universal {
+		kind = GeneralizedTime
+	}
+	switch kind.Tag() {
 	case Int.Tag():
 		return e.EncodeInt(val.Unix())
 	case UniversalTime.Tag():
 		if !validTimeUTC(val) {
-			return fmt.Errorf("%s: date outside utc range", val)
+			return encodeError(tag, fmt.Errorf("%s: date outside utc range", val))
 		}
 		pattern = patUniversTime
+		if e.rules != BER {
+			pattern = patUniversTimeZ
+		}
 	case GeneralizedTime.Tag():
 		if !validTimeGeneralized(val) {
-			return fmt.Errorf("%s: date outside generalized range", val)
+			return encodeError(tag, fmt.Errorf("%s: date outside generalized range", val))
 		}
 		pattern = patGeneralTime
+		if e.rules != BER {
+			pattern = patGeneralTimeZ
+		}
 	default:
-		return fmt.Errorf("invalid tag for time encoding")
+		return encodeError(tag, fmt.Errorf("invalid tag for time encoding"))
+	}
+	if e.rules != BER {
+		val = val.UTC()
 	}
 	str := val.Format(pattern)
 	return e.encodeBytes([]byte(str), tag)
@@ -294,7 +429,7 @@ func (e *Encoder) EncodeOIDWithIdent(val string, tag Ident) error {
 		tag = ObjectId
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("oid: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("oid: %w", ErrPrimitive))
 	}
 	var minlen int
 	if tag.Tag() == ObjectId.Tag() {
@@ -308,7 +443,7 @@ func (e *Encoder) EncodeOIDWithIdent(val string, tag Ident) error {
 func (e *Encoder) encodeOID(str string, min int, tag Ident) error {
 	ids, err := splitOID(str, min)
 	if err != nil {
-		return err
+		return encodeError(tag, err)
 	}
 	pdu := make([]byte, 0, len(ids))
 	var begin int
@@ -327,7 +462,7 @@ func (e *Encoder) encodeFloat(f float64, base int, tag Ident) error {
 		tag = Real
 	}
 	if tag.Type() != Primitive {
-		return fmt.Errorf("real: %w", ErrPrimitive)
+		return encodeError(tag, fmt.Errorf("real: %w", ErrPrimitive))
 	}
 	if b := encodeSpecialFloat(f); len(b) > 0 {
 		if b[0] == 0 {
@@ -338,13 +473,19 @@ func (e *Encoder) encodeFloat(f float64, base int, tag Ident) error {
 	var b []byte
 	switch base {
 	case 10:
+		if e.rules != BER {
+			b, e.err = encodeCanonicalDecimalFloat(f)
+			break
+		}
 		b, e.err = encodeDecimalFloat(f)
 	case 2:
 		b, e.err = encodeBinaryFloat(f, base)
 	default:
-		return fmt.Errorf("unsupported base %d", base)
+		return encodeError(tag, fmt.Errorf("unsupported base %d", base))
 	}
-	if e.err == nil {
+	if e.err != nil {
+		e.err = encodeError(tag, e.err)
+	} else {
 		e.err = e.encodeBytes(b, tag)
 	}
 	return e.err
@@ -369,6 +510,7 @@ func (e *Encoder) encodeBytes(b []byte, i Ident) error {
 		if len(b) > 0 {
 			e.buf = append(e.buf, b...)
 		}
+		return e.flush()
 	}
 	return e.err
 }
@@ -380,6 +522,15 @@ func (e *Encoder) encodeConstructed(i Ident) ([]byte, error) {
 	if e.err != nil {
 		return nil, e.err
 	}
+	if e.depth > 0 {
+		return nil, fmt.Errorf("%d indefinite value(s) not closed", e.depth)
+	}
+	if e.rules != BER {
+		i = i.Constructed()
+	}
+	if e.rules == CER {
+		return e.encodeIndefinite(i)
+	}
 	var (
 		id, errk = encodeIdentifier(i.Class(), i.Type(), i.Tag())
 		sz, errz = encodeLength(len(e.buf))
@@ -412,7 +563,7 @@ func (e *Encoder) merge(other *Encoder, tag Ident) error {
 		return e.err
 	}
 	e.buf = append(e.buf, buf...)
-	return e.err
+	return e.flush()
 }
 
 var (
@@ -421,14 +572,23 @@ var (
 )
 
 func (e *Encoder) encodeValue(val reflect.Value, tag Ident) error {
+	if names, ok := registeredNamedBits(val.Type()); ok {
+		e.err = e.encodeNamedBits(val, names, tag)
+		return e.err
+	}
 	switch val.Kind() {
 	case reflect.Struct:
-		if val.Type() == timetype {
-			e.err = e.EncodeWithIdent(val.Interface(), tag)
+		if t := val.Type(); t == timetype || t == bitstringtype || t == bigtype || t == taggedtype {
+			e.err = e.encode(val.Interface(), tag)
 			break
 		}
 		e.err = e.encodeStruct(val, tag)
 	case reflect.Slice, reflect.Array:
+		if val.Type() == bytestype && tag.Class() == Universal && tag.Tag() == BitString.Tag() {
+			bs := Bits{Bytes: val.Bytes(), BitLength: val.Len() * 8}
+			e.err = e.EncodeBitStringWithIdent(bs, tag)
+			break
+		}
 		if val.Type() == bytestype {
 			e.err = e.EncodeBytesWithIdent(val.Bytes(), tag)
 			break
@@ -446,8 +606,15 @@ func (e *Encoder) encodeValue(val reflect.Value, tag Ident) error {
 		if !val.CanInterface() {
 			break
 		}
-		e.err = e.EncodeWithIdent(val.Interface(), tag)
+		if c, ok := registeredChoice(val.Type()); ok {
+			e.err = e.encodeChoice(val, c, tag)
+			break
+		}
+		e.err = e.encode(val.Interface(), tag)
 	case reflect.String:
+		if val.Type() == oidtype {
+			tag = oidIdent(val.String(), tag)
+		}
 		if g := tag.Tag(); g == ObjectId.Tag() || g == RelObjectId.Tag() {
 			e.err = e.EncodeOIDWithIdent(val.String(), tag)
 		} else {
@@ -466,6 +633,15 @@ func (e *Encoder) encodeValue(val reflect.Value, tag Ident) error {
 	return e.err
 }
 
+func isNil(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return val.IsNil()
+	default:
+		return false
+	}
+}
+
 var identForKind = map[reflect.Kind]Ident{
 	reflect.Uint:    Int,
 	reflect.Uint8:   Int,
@@ -486,6 +662,26 @@ var identForKind = map[reflect.Kind]Ident{
 	// reflect.Map:     Sequence,
 }
 
+// encodeField encodes the value of the field sf. With explicit tagging, the
+// value is wrapped in a constructed element with the tag of the field.
+func (e *Encoder) encodeField(f reflect.Value, sf reflect.StructField, ft fieldTag) error {
+	if !ft.outer.isZero() {
+		var (
+			inner = e.child()
+			outer = ft.outer
+		)
+		ft.outer = 0
+		if err := inner.encodeField(f, sf, ft); err != nil {
+			return err
+		}
+		return e.merge(&inner, outer)
+	}
+	if names, ok := namedBitsForField(sf); ok {
+		return e.encodeNamedBits(f, names, ft.id)
+	}
+	return e.encodeValue(f, ft.id)
+}
+
 func (e *Encoder) encodeStruct(val reflect.Value, tag Ident) error {
 	if tag.isZero() {
 		tag = Sequence
@@ -494,35 +690,42 @@ func (e *Encoder) encodeStruct(val reflect.Value, tag Ident) error {
 	// 	return fmt.Errorf("struct: %w", ErrConstructed)
 	// }
 	var (
-		ex Encoder
-		tp = val.Type()
+		ex   = e.child()
+		tags = structTags(val.Type(), e.tagging)
 	)
 	for i := 0; i < val.NumField(); i++ {
 		var (
-			f    = val.Field(i)
-			sf   = tp.Field(i)
-			id   = identForKind[f.Kind()]
-			omit bool
-			err  error
+			f  = val.Field(i)
+			sf = val.Type().Field(i)
+			ft = tags[i]
+			id = ft.id
 		)
-		if sf.PkgPath != "" {
+		if ft.skip {
 			continue
 		}
-		if val.Type() == identtype {
-			if sf.Name == "Id" || sf.Tag.Get("ber") == "id" {
-				tag = val.Interface().(Ident)
-				continue
-			}
+		if ft.err != nil {
+			e.err = prefixPath(e.wrapError(ft.err, id), sf.Name)
+			return e.err
 		}
-		if tag := sf.Tag.Get("ber"); tag != "" {
-			if tag == "-" {
-				continue
+		if ft.ident {
+			if x := f.Interface().(Ident); !x.isZero() {
+				tag = x
 			}
-			id, omit, err = parseTag(tag, id)
+			continue
+		}
+		if ft.opts.optional && isNil(f) {
+			continue
+		} else if ft.opts.hasDef && e.rules != BER {
+			def, err := defaultValue(f.Type(), ft.opts.def)
 			if err != nil {
-				return err
+				e.err = prefixPath(e.wrapError(err, id), sf.Name)
+				return e.err
+			}
+			if reflect.DeepEqual(f.Interface(), def.Interface()) {
+				continue
 			}
 		}
+		omit := ft.omit
 		switch k := f.Kind(); {
 		default:
 			omit = false
@@ -538,7 +741,13 @@ func (e *Encoder) encodeStruct(val reflect.Value, tag Ident) error {
 		if omit {
 			continue
 		}
-		if err = ex.encodeValue(f, id); err != nil {
+		if err := ex.encodeField(f, sf, ft); err != nil {
+			e.err = prefixPath(e.wrapError(err, id), sf.Name)
+			return e.err
+		}
+	}
+	if e.rules != BER && tag.isSet() {
+		if err := ex.sortElements(compareTag, true); err != nil {
 			e.err = err
 			return e.err
 		}
@@ -553,37 +762,68 @@ func (e *Encoder) encodeArray(val reflect.Value, tag Ident) error {
 	// if tag.Type() != Constructed {
 	// 	return fmt.Errorf("array: %w", ErrConstructed)
 	// }
-	var ex Encoder
+	ex := e.child()
 	for i := 0; i < val.Len(); i++ {
-		if err := ex.encodeValue(val.Index(i), 0); err != nil {
+		var (
+			f  = val.Index(i)
+			id = identForKind[f.Kind()]
+		)
+		if err := ex.encodeValue(f, id); err != nil {
+			e.err = prefixPath(e.wrapError(err, id), indexPath(i))
+			return e.err
+		}
+	}
+	if e.rules != BER && tag.isSet() {
+		if err := ex.sortElements(compareEncoding, false); err != nil {
 			e.err = err
-			return err
+			return e.err
 		}
 	}
 	return e.merge(&ex, tag)
 }
 
+// encodeMap encodes the entries of a map as the components of a SET OF where
+// each entry is a SEQUENCE of its key and its value. With DER and CER, the
+// entries are ordered by their encoding (X.690 11.6) so that the output does
+// not depend on the iteration order of the map.
 func (e *Encoder) encodeMap(val reflect.Value, tag Ident) error {
 	if tag.isZero() {
-		tag = Sequence
+		tag = Set
 	}
 	// if tag.Type() != Constructed {
 	// 	return fmt.Errorf("map: %w", ErrConstructed)
 	// }
-	var ex Encoder
+	ex := e.child()
 	for _, k := range val.MapKeys() {
-		if err := ex.encodeValue(k, 0); err != nil {
+		ek := e.child()
+		if err := ek.encodeMapEntry(k, val.MapIndex(k)); err != nil {
 			e.err = err
-			return err
+			return e.err
 		}
-		if err := ex.encodeValue(val.MapIndex(k), 0); err != nil {
+		if err := ex.merge(&ek, Sequence); err != nil {
 			e.err = err
-			return err
+			return e.err
+		}
+	}
+	if e.rules != BER {
+		if err := ex.sortElements(compareEncoding, false); err != nil {
+			e.err = err
+			return e.err
 		}
 	}
 	return e.merge(&ex, tag)
 }
 
+func (e *Encoder) encodeMapEntry(k, v reflect.Value) error {
+	if err := e.encodeValue(k, identForKind[k.Kind()]); err != nil {
+		return prefixPath(e.wrapError(err, identForKind[k.Kind()]), keyPath(k))
+	}
+	if err := e.encodeValue(v, identForKind[v.Kind()]); err != nil {
+		return prefixPath(e.wrapError(err, identForKind[v.Kind()]), keyPath(k))
+	}
+	return nil
+}
+
 func encodeIdentifier(klass, kind uint8, tag uint32) ([]byte, error) {
 	if klass > Private {
 		return nil, fmt.Errorf("invalid class(%02x) given", klass)
@@ -758,24 +998,19 @@ func encodeUint(i uint64, less bool) []byte {
 	return c
 }
 
+// encodeInt encodes i in the minimal number of octets of its two's complement
+// representation.
 func encodeInt(i int64) []byte {
-	var x uint64
-	switch {
-	case i > 0:
-		x = uint64(i)
-	case i < 0:
-		var (
-			zero = bits.LeadingZeros64(uint64(^i))
-			mask = uint64(1<<zero - 1)
-		)
-		if mod := zero % 8; mod > 0 {
-			zero -= mod
-		}
-		x = uint64(i) ^ (mask << (64 - zero))
-	default:
-		return []byte{0x00}
+	n := 1
+	for x := i; x > math.MaxInt8 || x < math.MinInt8; x >>= 8 {
+		n++
+	}
+	b := make([]byte, n)
+	for j := n - 1; j >= 0; j-- {
+		b[j] = byte(i)
+		i >>= 8
 	}
-	return encodeUint(x, i < 0)
+	return b
 }
 
 func splitOID(str string, min int) ([]uint32, error) {
@@ -20,6 +20,63 @@ func TestEncoder(t *testing.T) {
 	t.Run("array/int", testEncodeArrayInt)
 	t.Run("array/string", testEncodeArrayString)
 	t.Run("struct", testEncodeStruct)
+	t.Run("indefinite", testEncodeIndefinite)
+}
+
+func testEncodeIndefinite(t *testing.T) {
+	var (
+		e    Encoder
+		out  bytes.Buffer
+		want = []byte{
+			0x30, 0x80,
+			0x02, 0x01, 0x00,
+			0x02, 0x01, 0x01,
+			0x31, 0x80,
+			0x02, 0x01, 0x02,
+			0x00, 0x00,
+			0x00, 0x00,
+		}
+	)
+	if err := e.EncodeIndefinite(Sequence); err != nil {
+		t.Fatalf("indefinite: fail to open value! %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := e.EncodeInt(int64(i)); err != nil {
+			t.Fatalf("indefinite: fail to encode int! %s", err)
+		}
+		if _, err := e.WriteTo(&out); err != nil {
+			t.Fatalf("indefinite: fail to write! %s", err)
+		}
+	}
+	if err := e.EncodeIndefinite(Set); err != nil {
+		t.Fatalf("indefinite: fail to open value! %s", err)
+	}
+	if err := e.EncodeInt(2); err != nil {
+		t.Fatalf("indefinite: fail to encode int! %s", err)
+	}
+	if err := e.EncodeEndOfContents(); err != nil {
+		t.Fatalf("indefinite: fail to close value! %s", err)
+	}
+	if _, err := e.AsSequence(); err == nil {
+		t.Errorf("indefinite: unclosed value should be reported")
+	}
+	if err := e.EncodeEndOfContents(); err != nil {
+		t.Fatalf("indefinite: fail to close value! %s", err)
+	}
+	if err := e.EncodeEndOfContents(); err == nil {
+		t.Errorf("indefinite: closing unopened value should fail")
+	}
+	if _, err := e.WriteTo(&out); err != nil {
+		t.Fatalf("indefinite: fail to write! %s", err)
+	}
+	if got := out.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("indefinite: bytes mismatched! want %x, got %x", want, got)
+	}
+
+	der := Encoder{rules: DER}
+	if err := der.EncodeIndefinite(Sequence); err == nil {
+		t.Errorf("indefinite: should be rejected in DER")
+	}
 }
 
 func testEncodeAs(t *testing.T) {
@@ -191,6 +248,7 @@ func testEncodeInt(t *testing.T) {
 		{Input: -129, Want: []byte{0x02, 0x02, 0xFF, 0x7F}},
 		{Input: 56, Want: []byte{0x02, 0x01, 0x38}},
 		{Input: -56, Want: []byte{0x02, 0x01, 0xc8}},
+		{Input: -1, Want: []byte{0x02, 0x01, 0xff}},
 		{Input: 512456, Want: []byte{0x02, 0x03, 0x07, 0xd1, 0xc8}},
 		{Input: -512456, Want: []byte{0x02, 0x03, 0xf8, 0x2e, 0x38}},
 	}
@@ -226,22 +284,23 @@ func testEncodeStruct(t *testing.T) {
 			Str:  "foobar",
 			Oid:  "1.2.840.113549.1.1.11",
 			When: time.Date(2019, 12, 15, 19, 2, 10, 0, time.UTC),
+			// a single entry as the order of the entries depends on the
+			// iteration order of the map
 			Set: map[string]int{
 				"foo": 128,
-				"bar": -128,
 			},
 			Bool: false,
 			Ifi:  nil,
 			omit: "unexported",
 		}
 		want = []byte{
-			0x30, 0x46,
+			0x30, 0x40,
 			0x02, 0x01, 0x80, // int
 			0xc2, 0x01, 0x7F, // uint
 			0x33, 0x06, 'f', 'o', 'o', 'b', 'a', 'r', // string
 			0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x0b, // oid: invalid encoded via stringwithident instead of oidwithident
 			0x18, 0x13, 0x32, 0x30, 0x31, 0x39, 0x31, 0x32, 0x31, 0x35, 0x31, 0x39, 0x30, 0x32, 0x31, 0x30, 0x2b, 0x30, 0x30, 0x30, 0x30, // time
-			0x31, 0x11, 0x0c, 0x03, 'f', 'o', 'o', 0x02, 0x02, 0x00, 0x80, 0x0c, 0x03, 'b', 'a', 'r', 0x02, 0x01, 0x80, // map[string]int
+			0x31, 0x0b, 0x30, 0x09, 0x0c, 0x03, 'f', 'o', 'o', 0x02, 0x02, 0x00, 0x80, // map[string]int
 			0x01, 0x01, 0x00, // bool
 			0x05, 0x00, // nil
 		}
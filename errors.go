@@ -0,0 +1,194 @@
+package ber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// SyntaxError describes an input that is not a valid encoding: truncated
+// element, invalid length or malformed contents.
+type SyntaxError struct {
+	// offset of the element in the input of the Decoder
+	Offset int
+	// identifier of the element
+	Ident Ident
+	// path of the Go value being decoded (eg: Message.Body.Items[3].Name)
+	Path string
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return formatError("syntax error", e.Offset, e.Ident, 0, e.Path, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// StructuralError describes a valid encoding that does not match the Go value
+// it is decoded into or a Go value that can not be encoded. Offset is -1 when
+// the error is returned by an Encoder.
+type StructuralError struct {
+	// offset of the element in the input of the Decoder
+	Offset int
+	// identifier of the element
+	Ident Ident
+	// identifier expected for the Go value if known
+	Expected Ident
+	// path of the Go value being decoded or encoded
+	Path string
+	Err  error
+}
+
+func (e *StructuralError) Error() string {
+	return formatError("structural error", e.Offset, e.Ident, e.Expected, e.Path, e.Err)
+}
+
+func (e *StructuralError) Unwrap() error {
+	return e.Err
+}
+
+func formatError(kind string, offset int, id, want Ident, path string, err error) string {
+	var str strings.Builder
+	str.WriteString(kind)
+	if path != "" {
+		str.WriteString(" in ")
+		str.WriteString(path)
+	}
+	if offset >= 0 {
+		fmt.Fprintf(&str, " at offset %d", offset)
+	}
+	if !id.isZero() {
+		fmt.Fprintf(&str, " (%s", id)
+		if !want.isZero() && want != id {
+			fmt.Fprintf(&str, ", expected %s", want)
+		}
+		str.WriteString(")")
+	}
+	str.WriteString(": ")
+	str.WriteString(err.Error())
+	return str.String()
+}
+
+// isStructural reports whether err is caused by a mismatch between an element
+// and a Go value rather than by a malformed encoding.
+func isStructural(err error) bool {
+	for _, e := range []error{ErrPrimitive, ErrConstructed, ErrOverflow, ErrTooDeep} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTyped(err error) bool {
+	var (
+		se *SyntaxError
+		te *StructuralError
+	)
+	return errors.As(err, &se) || errors.As(err, &te)
+}
+
+// wrapError gives *err the type of the error describing a failure when decoding
+// the element starting at offset. io.EOF returned when a stream has no more
+// elements and errors already typed are left untouched.
+func (d *Decoder) wrapError(err *error, offset int) {
+	if *err == nil || *err == io.EOF || isTyped(*err) {
+		return
+	}
+	var id Ident
+	if offset < len(d.buf) {
+		id, _, _ = decodeIdentifier(d.buf[offset:])
+	}
+	if isStructural(*err) {
+		*err = &StructuralError{
+			Offset: offset,
+			Ident:  id,
+			Err:    *err,
+		}
+		return
+	}
+	*err = &SyntaxError{
+		Offset: offset,
+		Ident:  id,
+		Err:    *err,
+	}
+}
+
+// structuralError gives the error returned when the element starting at offset
+// can not be decoded in a Go value.
+func (d *Decoder) structuralError(offset int, want Ident, err error) error {
+	var id Ident
+	if offset < len(d.buf) {
+		id, _, _ = decodeIdentifier(d.buf[offset:])
+	}
+	return &StructuralError{
+		Offset:   offset,
+		Ident:    id,
+		Expected: want,
+		Err:      err,
+	}
+}
+
+// wrapError gives the error describing a failure when encoding a value with the
+// given tag. Errors of the writer of a stream Encoder are left untouched.
+func (e *Encoder) wrapError(err error, tag Ident) error {
+	if err == nil || err == e.werr || isTyped(err) {
+		return err
+	}
+	return encodeError(tag, err)
+}
+
+// encodeError gives the error describing a value that can not be encoded as an
+// element identified by tag.
+func encodeError(tag Ident, err error) error {
+	return &StructuralError{
+		Offset: -1,
+		Ident:  tag,
+		Err:    err,
+	}
+}
+
+// prefixPath prepends elem to the path of err.
+func prefixPath(err error, elem string) error {
+	var (
+		se *SyntaxError
+		te *StructuralError
+	)
+	switch {
+	case errors.As(err, &se):
+		se.Path = joinPath(elem, se.Path)
+	case errors.As(err, &te):
+		te.Path = joinPath(elem, te.Path)
+	}
+	return err
+}
+
+// rootPath prepends the name of typ to the path of err.
+func rootPath(err error, typ reflect.Type) error {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil {
+		return err
+	}
+	return prefixPath(err, typ.Name())
+}
+
+func joinPath(elem, path string) string {
+	if elem == "" || path == "" || path[0] == '[' {
+		return elem + path
+	}
+	return elem + "." + path
+}
+
+func indexPath(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+func keyPath(k reflect.Value) string {
+	return fmt.Sprintf("[%v]", k)
+}
@@ -0,0 +1,235 @@
+package ber
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+)
+
+type errItem struct {
+	Name string
+	Size int8
+}
+
+type errBody struct {
+	Items []errItem
+}
+
+type errMessage struct {
+	Id   int
+	Body errBody
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("syntax", testSyntaxError)
+	t.Run("structural", testStructuralError)
+	t.Run("encode", testEncodeError)
+	t.Run("encoder", testEncoderError)
+	t.Run("eof", testErrorEOF)
+	t.Run("depth", testErrorDepth)
+}
+
+func errItems(last []byte) []byte {
+	item := []byte{0x30, 0x06, 0x0c, 0x01, 0x61, 0x02, 0x01, 0x01}
+	var items []byte
+	for i := 0; i < 3; i++ {
+		items = append(items, item...)
+	}
+	items = append(items, last...)
+	buf := []byte{0x30, byte(len(items))}
+	buf = append(buf, items...)
+	body := append([]byte{0x30, byte(len(buf))}, buf...)
+	msg := []byte{0x02, 0x01, 0x01}
+	msg = append(msg, body...)
+	return append([]byte{0x30, byte(len(msg))}, msg...)
+}
+
+func testSyntaxError(t *testing.T) {
+	// the length of the name of the last item is greater than its contents
+	buf := errItems([]byte{0x30, 0x03, 0x0c, 0x05, 0x61})
+
+	var (
+		msg errMessage
+		err = NewDecoder(buf).Decode(&msg)
+		se  *SyntaxError
+	)
+	if !errors.As(err, &se) {
+		t.Fatalf("syntax: expected *SyntaxError, got %T (%v)", err, err)
+	}
+	if want := "errMessage.Body.Items[3].Name"; se.Path != want {
+		t.Errorf("syntax: path mismatched! want %s, got %s", want, se.Path)
+	}
+	if want := 35; se.Offset != want {
+		t.Errorf("syntax: offset mismatched! want %d, got %d", want, se.Offset)
+	}
+	if se.Ident != UTF8String {
+		t.Errorf("syntax: ident mismatched! want %s, got %s", UTF8String, se.Ident)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("syntax: truncated error not found in %v", err)
+	}
+}
+
+func testStructuralError(t *testing.T) {
+	buf := errItems([]byte{0x30, 0x07, 0x0c, 0x01, 0x61, 0x02, 0x02, 0x01, 0x00})
+
+	var (
+		msg errMessage
+		err = NewDecoder(buf).Decode(&msg)
+		se  *StructuralError
+	)
+	if !errors.As(err, &se) {
+		t.Fatalf("structural: expected *StructuralError, got %T (%v)", err, err)
+	}
+	if want := "errMessage.Body.Items[3].Size"; se.Path != want {
+		t.Errorf("structural: path mismatched! want %s, got %s", want, se.Path)
+	}
+	if want := 38; se.Offset != want {
+		t.Errorf("structural: offset mismatched! want %d, got %d", want, se.Offset)
+	}
+	if !errors.Is(err, ErrOverflow) {
+		t.Errorf("structural: overflow error not found in %v", err)
+	}
+
+	var n int
+	err = NewDecoder([]byte{0x30, 0x00}).Decode(&n)
+	if !errors.As(err, &se) || !errors.Is(err, ErrPrimitive) {
+		t.Errorf("structural: expected *StructuralError, got %T (%v)", err, err)
+	}
+}
+
+func testEncodeError(t *testing.T) {
+	type option struct {
+		Value string `ber:"tag:foo"`
+	}
+	type config struct {
+		Options map[string]option
+	}
+	var (
+		e   Encoder
+		err = e.Encode(config{Options: map[string]option{"dev": {}}})
+		se  *StructuralError
+	)
+	if !errors.As(err, &se) {
+		t.Fatalf("encode: expected *StructuralError, got %T (%v)", err, err)
+	}
+	if want := "config.Options[dev].Value"; se.Path != want {
+		t.Errorf("encode: path mismatched! want %s, got %s", want, se.Path)
+	}
+	if se.Offset != -1 {
+		t.Errorf("encode: offset should not be set (got %d)", se.Offset)
+	}
+}
+
+func testEncoderError(t *testing.T) {
+	var (
+		seq  = Sequence.Constructed()
+		ctx  = NewConstructed(0).Context()
+		data = []struct {
+			Name  string
+			Ident Ident
+			Func  func(*Encoder) error
+		}{
+			{
+				Name:  "string",
+				Ident: PrintableString,
+				Func: func(e *Encoder) error {
+					return e.EncodeStringPrintable("foo@bar")
+				},
+			},
+			{
+				Name:  "int",
+				Ident: seq,
+				Func: func(e *Encoder) error {
+					return e.EncodeIntWithIdent(1, seq)
+				},
+			},
+			{
+				Name:  "bitstring",
+				Ident: BitString,
+				Func: func(e *Encoder) error {
+					return e.EncodeBitString(Bits{Bytes: []byte{0xFF}, BitLength: 9})
+				},
+			},
+			{
+				Name:  "bigint",
+				Ident: ctx,
+				Func: func(e *Encoder) error {
+					return e.EncodeBigIntWithIdent(big.NewInt(1), ctx)
+				},
+			},
+		}
+	)
+	for _, d := range data {
+		var (
+			e   Encoder
+			err = d.Func(&e)
+			se  *StructuralError
+		)
+		if !errors.As(err, &se) {
+			t.Errorf("%s: expected *StructuralError, got %T (%v)", d.Name, err, err)
+			continue
+		}
+		if se.Ident != d.Ident {
+			t.Errorf("%s: ident mismatched! want %s, got %s", d.Name, d.Ident, se.Ident)
+		}
+		if se.Offset != -1 {
+			t.Errorf("%s: offset should not be set (got %d)", d.Name, se.Offset)
+		}
+	}
+}
+
+func testErrorEOF(t *testing.T) {
+	var (
+		d   = NewStreamDecoder(new(eofReader))
+		msg errMessage
+	)
+	if err := d.Decode(&msg); err != io.EOF {
+		t.Errorf("eof: expected io.EOF, got %v", err)
+	}
+}
+
+type eofReader struct{}
+
+func testErrorDepth(t *testing.T) {
+	var buf []byte
+	for i := 0; i < 100000; i++ {
+		buf = append(buf, 0x30, 0x80)
+	}
+	for i := 0; i < 100000; i++ {
+		buf = append(buf, 0x00, 0x00)
+	}
+	check := func(name string, err error) {
+		t.Helper()
+		var se *StructuralError
+		if !errors.As(err, &se) {
+			t.Errorf("%s: expected *StructuralError, got %T (%v)", name, err, err)
+			return
+		}
+		if !errors.Is(err, ErrTooDeep) {
+			t.Errorf("%s: depth error not found in %v", name, err)
+		}
+	}
+	var v interface{}
+	check("decode", NewDecoder(buf).Decode(&v))
+	check("skip", NewDecoder(buf).Skip())
+	_, err := Parse(buf)
+	check("parse", err)
+	_, err = Select(buf, "/0/0/0")
+	check("select", err)
+	check("dump", Dump(io.Discard, buf, DumpOptions{}))
+
+	it := NewIterator(buf)
+	for i := 0; it.Next() && i <= maxDepth; i++ {
+		it = it.Descend()
+	}
+	check("iterator", it.Err())
+	if _, err := measure(buf); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("measure: depth error not found in %v", err)
+	}
+}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
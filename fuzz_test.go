@@ -0,0 +1,135 @@
+package ber
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type fuzzRecord struct {
+	Name    string
+	Flag    bool
+	Small   int8
+	Count   uint32
+	Value   int64
+	Ratio   float64
+	When    time.Time
+	Data    []byte
+	Bits    Bits
+	Usage   uint16 `ber:"names:a|b|c"`
+	Serial  *big.Int
+	Items   []string
+	Labels  map[string]int
+	Options []int `ber:"set"`
+	Inner   struct {
+		Id  int
+		Raw Raw
+	}
+}
+
+func fuzzSeeds() [][]byte {
+	seeds := [][]byte{
+		{},
+		{0x00},
+		{0x1f},
+		{0x1f, 0x81},
+		{0x30, 0x80},
+		{0x30, 0x84, 0xff, 0xff, 0xff, 0xff},
+		{0x02, 0x09, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x04, 0x05, 0x01},
+		{0x24, 0x80, 0x04, 0x01, 0x61, 0x00, 0x00},
+		{0x03, 0x03, 0x07, 0xff, 0x80},
+		{0x09, 0x03, 0x80, 0x00, 0x01},
+		{0x09, 0x01, 0x83},
+		{0x06, 0x03, 0x2a, 0x86, 0x48},
+		{0x18, 0x0f, '2', '0', '2', '1', '0', '1', '0', '2', '1', '5', '0', '4', '0', '5', 'Z'},
+	}
+	var e Encoder
+	rec := fuzzRecord{
+		Name:    "fuzz",
+		Flag:    true,
+		Small:   -3,
+		Count:   300,
+		Value:   -1 << 40,
+		Ratio:   0.15625,
+		When:    time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC),
+		Data:    []byte("data"),
+		Bits:    Bits{Bytes: []byte{0xa0}, BitLength: 3},
+		Usage:   5,
+		Serial:  big.NewInt(1 << 62),
+		Items:   []string{"foo", "bar"},
+		Labels:  map[string]int{"a": 1, "b": 2},
+		Options: []int{3, 1, 2},
+	}
+	rec.Inner.Id = 7
+	rec.Inner.Raw = Raw{0x05, 0x00}
+	if err := e.Encode(rec); err == nil {
+		seeds = append(seeds, e.Bytes())
+	}
+	return seeds
+}
+
+// FuzzDecode checks that decoding arbitrary input never panics, whatever the
+// value decoded and the rules selected.
+func FuzzDecode(f *testing.F) {
+	for _, s := range fuzzSeeds() {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, r := range []Rules{BER, DER, CER} {
+			fuzzDecoder(b, r)
+		}
+		d := NewStreamDecoder(bytes.NewReader(b))
+		for {
+			var rec fuzzRecord
+			if err := d.Decode(&rec); err != nil {
+				break
+			}
+		}
+	})
+}
+
+func fuzzDecoder(b []byte, r Rules) {
+	var (
+		rec   fuzzRecord
+		list  []interface{}
+		value big.Int
+		raw   Raw
+	)
+	targets := []func(*Decoder) error{
+		func(d *Decoder) error { return d.Decode(&rec) },
+		func(d *Decoder) error { return d.Decode(&list) },
+		func(d *Decoder) error { return d.Decode(&value) },
+		func(d *Decoder) error { return d.Decode(&raw) },
+		func(d *Decoder) error { _, err := d.DecodeString(); return err },
+		func(d *Decoder) error { _, err := d.DecodeBytes(); return err },
+		func(d *Decoder) error { _, err := d.DecodeBool(); return err },
+		func(d *Decoder) error { _, err := d.DecodeInt(); return err },
+		func(d *Decoder) error { _, err := d.DecodeUint(); return err },
+		func(d *Decoder) error { _, err := d.DecodeFloat(); return err },
+		func(d *Decoder) error { _, err := d.DecodeOID(); return err },
+		func(d *Decoder) error { _, err := d.DecodeTime(); return err },
+		func(d *Decoder) error { _, err := d.DecodeBitString(); return err },
+		func(d *Decoder) error { return d.DecodeNull() },
+		func(d *Decoder) error { return d.Skip() },
+		func(d *Decoder) error {
+			_, _, err := d.DecodeTagged()
+			d.DecodeEndOfContents()
+			return err
+		},
+	}
+	for _, fn := range targets {
+		d := NewDecoder(b)
+		d.SetRules(r)
+		d.Peek()
+		d.Need()
+		d.Can()
+		for !d.Empty() {
+			offset := d.offset
+			if fn(d) != nil || d.offset == offset {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package ber
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// OID is an OBJECT IDENTIFIER in its dotted form (eg: 1.2.840.113549). A
+// RELATIVE-OID starts with a dot.
+type OID string
+
+func (o OID) String() string {
+	return string(o)
+}
+
+// Relative reports whether o is a RELATIVE-OID.
+func (o OID) Relative() bool {
+	return strings.HasPrefix(string(o), ".")
+}
+
+// Tagged is an element decoded into an interface{} that has no Go equivalent:
+// elements of the application, context specific and private classes and
+// universal types without a Go equivalent listed by Decoder.Decode.
+//
+// Value is the list of the elements of a constructed element as a []interface{}
+// and the contents of a primitive element as a []byte.
+type Tagged struct {
+	Ident Ident
+	Value interface{}
+}
+
+var (
+	oidtype    = reflect.TypeOf(OID(""))
+	taggedtype = reflect.TypeOf(Tagged{})
+)
+
+// oidIdent gives the identifier used to encode o when it is given the universal
+// identifier tag.
+func oidIdent(o string, tag Ident) Ident {
+	if tag.Class() != Universal {
+		return tag
+	}
+	if strings.HasPrefix(o, ".") {
+		return RelObjectId
+	}
+	return ObjectId
+}
+
+// decodeGeneric decodes the next element into a value of the Go type matching
+// its identifier (see Decoder.Decode).
+func (d *Decoder) decodeGeneric() (interface{}, error) {
+	id, err := d.Peek()
+	if err != nil {
+		return nil, err
+	}
+	if id.Class() != Universal {
+		return d.decodeTagged(id)
+	}
+	switch id.Tag() {
+	case Bool.Tag():
+		return d.DecodeBool()
+	case Int.Tag(), Enumerated.Tag():
+		x, err := d.DecodeBigInt()
+		if err != nil {
+			return nil, err
+		}
+		if x.IsInt64() {
+			return x.Int64(), nil
+		}
+		return x, nil
+	case BitString.Tag():
+		return d.DecodeBitString()
+	case OctetString.Tag():
+		return d.DecodeBytes()
+	case Null.Tag():
+		return nil, d.DecodeNull()
+	case ObjectId.Tag(), RelObjectId.Tag():
+		oid, err := d.DecodeOID()
+		return OID(oid), err
+	case Real.Tag():
+		return d.DecodeFloat()
+	case UTF8String.Tag(), PrintableString.Tag(), IA5String.Tag():
+		return d.DecodeString()
+	case 0x12, 0x14, 0x15, 0x19, 0x1a, 0x1b, 0x1c, 0x1e:
+		return d.DecodeString()
+	case UniversalTime.Tag(), GeneralizedTime.Tag():
+		return d.DecodeTime()
+	case Sequence.Tag(), Set.Tag():
+		if id.Type() != Constructed {
+			return nil, fmt.Errorf("sequence: %w", ErrConstructed)
+		}
+		_, list, err := d.decodeElements()
+		return list, err
+	default:
+		return d.decodeTagged(id)
+	}
+}
+
+// decodeTagged decodes the next element with the identifier id in a Tagged.
+func (d *Decoder) decodeTagged(id Ident) (interface{}, error) {
+	if id.Type() == Constructed {
+		_, list, err := d.decodeElements()
+		return Tagged{Ident: id, Value: list}, err
+	}
+	_, n, err := d.decodeIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	d.offset += n
+	size, n, err := d.decodeLength()
+	if err != nil {
+		return nil, err
+	}
+	if size == indefinite {
+		return nil, fmt.Errorf("primitive element with indefinite length")
+	}
+	d.offset += n + size
+	buf := make([]byte, size)
+	copy(buf, d.buf[d.offset-size:d.offset])
+	return Tagged{Ident: id, Value: buf}, nil
+}
+
+// decodeElements decodes the elements of the next constructed element into
+// a []interface{}.
+func (d *Decoder) decodeElements() (Ident, []interface{}, error) {
+	id, n, err := d.decodeIdentifier()
+	if err != nil {
+		return id, nil, err
+	}
+	d.offset += n
+	size, n, err := d.decodeLength()
+	if err != nil {
+		return id, nil, err
+	}
+	d.offset += n
+	if err := d.enter(); err != nil {
+		return id, nil, err
+	}
+	defer d.exit()
+	var (
+		limit = d.limit(size)
+		list  = []interface{}{}
+		prev  element
+	)
+	for d.more(limit) {
+		if id.isSet() {
+			curr, err := d.checkSetOrder(prev, compareEncoding)
+			if err != nil {
+				return id, nil, err
+			}
+			prev = curr
+		}
+		offset := d.offset
+		v, err := d.decodeGeneric()
+		if err != nil {
+			d.wrapError(&err, offset)
+			return id, nil, prefixPath(err, indexPath(len(list)))
+		}
+		if limit != indefinite && d.offset > limit {
+			return id, nil, fmt.Errorf("%s: too many bytes consumed to decode value", id)
+		}
+		list = append(list, v)
+	}
+	return id, list, d.leave(limit)
+}
+
+// decodeInterface decodes the next element into the interface{} val.
+func (d *Decoder) decodeInterface(val reflect.Value) error {
+	v, err := d.decodeGeneric()
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		val.Set(reflect.Zero(val.Type()))
+	} else {
+		val.Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// encodeTagged encodes the Value of t with the identifier of t.
+func (e *Encoder) encodeTagged(t Tagged, tag Ident) error {
+	if tag.isZero() {
+		tag = t.Ident
+	}
+	if buf, ok := t.Value.([]byte); ok && tag.Type() == Primitive {
+		return e.encodeBytes(buf, tag)
+	}
+	if list, ok := t.Value.([]interface{}); ok {
+		ex := e.child()
+		for i, v := range list {
+			if err := ex.encode(v, 0); err != nil {
+				return prefixPath(ex.wrapError(err, 0), indexPath(i))
+			}
+		}
+		return e.merge(&ex, tag)
+	}
+	return e.encode(t.Value, tag)
+}
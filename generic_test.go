@@ -0,0 +1,139 @@
+package ber
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGeneric(t *testing.T) {
+	t.Run("decode", testDecodeGeneric)
+	t.Run("encode", testEncodeGeneric)
+	t.Run("field", testGenericField)
+}
+
+type genericRecord struct {
+	Name   string
+	Flag   bool
+	Count  int
+	Large  *big.Int
+	Ratio  float64
+	When   time.Time
+	Type   OID
+	Data   []byte
+	Bits   Bits
+	Empty  *int
+	Items  []string
+	Tagged int `ber:"tag:3,class:2"`
+	Inner  struct {
+		Value int
+	} `ber:"tag:1,class:1,type:1"`
+}
+
+var genericTime = time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func genericInput() genericRecord {
+	rec := genericRecord{
+		Name:   "generic",
+		Flag:   true,
+		Count:  -42,
+		Large:  bigInt("0x0102030405060708090a"),
+		Ratio:  0.5,
+		When:   genericTime,
+		Type:   "1.2.840.113549",
+		Data:   []byte("data"),
+		Bits:   Bits{Bytes: []byte{0xa0}, BitLength: 3},
+		Items:  []string{"foo", "bar"},
+		Tagged: 7,
+	}
+	rec.Inner.Value = 1
+	return rec
+}
+
+func genericWant() []interface{} {
+	return []interface{}{
+		"generic",
+		true,
+		int64(-42),
+		bigInt("0x0102030405060708090a"),
+		0.5,
+		genericTime,
+		OID("1.2.840.113549"),
+		[]byte("data"),
+		Bits{Bytes: []byte{0xa0}, BitLength: 3},
+		nil,
+		[]interface{}{"foo", "bar"},
+		Tagged{Ident: NewPrimitive(3).Context(), Value: []byte{0x07}},
+		Tagged{Ident: NewConstructed(1).Application(), Value: []interface{}{int64(1)}},
+	}
+}
+
+func testDecodeGeneric(t *testing.T) {
+	var e Encoder
+	e.SetRules(DER)
+	if err := e.Encode(genericInput()); err != nil {
+		t.Fatalf("decode: fail to encode record! %s", err)
+	}
+	var (
+		got interface{}
+		d   = NewDecoder(e.Bytes())
+	)
+	d.SetRules(DER)
+	if err := d.Decode(&got); err != nil {
+		t.Fatalf("decode: fail to decode record! %s", err)
+	}
+	if want := genericWant(); !reflect.DeepEqual(got, want) {
+		t.Errorf("decode: values mismatched!\nwant: %#v\ngot:  %#v", want, got)
+	}
+}
+
+func testEncodeGeneric(t *testing.T) {
+	in := []interface{}{
+		OID("1.2.840"),
+		OID(".8.1"),
+		nil,
+		Tagged{Ident: NewPrimitive(3).Context(), Value: []byte{0x07}},
+		Tagged{Ident: NewConstructed(1).Application(), Value: []interface{}{int64(1), true}},
+	}
+	want := []byte{
+		0x30, 0x16,
+		0x06, 0x03, 0x2a, 0x86, 0x48,
+		0x0d, 0x02, 0x08, 0x01,
+		0x05, 0x00,
+		0x83, 0x01, 0x07,
+		0x61, 0x06, 0x02, 0x01, 0x01, 0x01, 0x01, 0xff,
+	}
+	var e Encoder
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("encode: fail to encode generic value! %s", err)
+	}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("encode: bytes mismatched! want %x, got %x", want, got)
+	}
+	var got interface{}
+	if err := NewDecoder(want).Decode(&got); err != nil {
+		t.Fatalf("encode: fail to decode generic value! %s", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("encode: values mismatched! want %#v, got %#v", in, got)
+	}
+}
+
+func testGenericField(t *testing.T) {
+	type message struct {
+		Kind    int
+		Payload interface{}
+	}
+	buf := []byte{0x30, 0x0a, 0x02, 0x01, 0x01, 0x30, 0x05, 0x0c, 0x01, 0x61, 0x05, 0x00}
+
+	var msg message
+	if err := NewDecoder(buf).Decode(&msg); err != nil {
+		t.Fatalf("field: fail to decode message! %s", err)
+	}
+	want := message{Kind: 1, Payload: []interface{}{"a", nil}}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("field: values mismatched! want %#v, got %#v", want, msg)
+	}
+}
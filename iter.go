@@ -0,0 +1,110 @@
+package ber
+
+import (
+	"fmt"
+)
+
+// Iterator scans the elements of a buffer without copying nor allocating. The
+// contents it gives share the memory of the buffer.
+//
+//	it := NewIterator(buf)
+//	for it.Next() {
+//		if it.Ident() == Sequence {
+//			child := it.Descend()
+//			for child.Next() {
+//				...
+//			}
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator struct {
+	d    Decoder
+	curr span
+	err  error
+}
+
+// NewIterator creates an Iterator over the elements of buf.
+func NewIterator(buf []byte) Iterator {
+	return Iterator{
+		d: Decoder{buf: buf},
+	}
+}
+
+// Next moves to the next element. It reports false when there is no element
+// left or when an element is invalid.
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.d.Empty() {
+		return false
+	}
+	s, err := it.d.span(it.d.offset)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.curr = s
+	it.d.offset = s.end
+	return true
+}
+
+// Ident gives the identifier of the current element.
+func (it *Iterator) Ident() Ident {
+	return it.curr.id
+}
+
+// Header gives the number of bytes of the identifier and of the length of the
+// current element.
+func (it *Iterator) Header() int {
+	return it.curr.content - it.curr.offset
+}
+
+// Indefinite reports whether the current element is encoded with the
+// indefinite form of the length.
+func (it *Iterator) Indefinite() bool {
+	return it.curr.limit != it.curr.end
+}
+
+// Content gives the contents of the current element. The end-of-contents of an
+// element encoded with the indefinite form is not included.
+func (it *Iterator) Content() []byte {
+	return it.d.buf[it.curr.content:it.curr.limit:it.curr.limit]
+}
+
+// Raw gives the encoding of the current element.
+func (it *Iterator) Raw() Raw {
+	return Raw(it.d.buf[it.curr.offset:it.curr.end:it.curr.end])
+}
+
+// Offset gives the offset of the current element from the start of the buffer
+// given to NewIterator.
+func (it *Iterator) Offset() int {
+	return it.curr.offset
+}
+
+// Descend gives an Iterator over the elements of the current element. Offsets
+// of the returned Iterator are relative to the buffer given to NewIterator.
+func (it *Iterator) Descend() Iterator {
+	if it.curr.id.Type() != Constructed {
+		return Iterator{
+			err: fmt.Errorf("%s: %w", it.curr.id, ErrConstructed),
+		}
+	}
+	child := Iterator{
+		d: Decoder{
+			buf:    it.d.buf[:it.curr.limit],
+			offset: it.curr.content,
+			depth:  it.d.depth,
+		},
+	}
+	if err := child.d.enter(); err != nil {
+		child.d.wrapError(&err, it.curr.offset)
+		child.err = err
+	}
+	return child
+}
+
+// Err gives the error that stopped the Iterator if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,104 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	t.Run("scan", testIteratorScan)
+	t.Run("errors", testIteratorErrors)
+	t.Run("allocs", testIteratorAllocs)
+}
+
+func testIteratorScan(t *testing.T) {
+	type element struct {
+		Ident      Ident
+		Offset     int
+		Header     int
+		Content    []byte
+		Indefinite bool
+	}
+	var (
+		it   = NewIterator(selectBytes)
+		list []element
+		walk func(it Iterator)
+	)
+	walk = func(it Iterator) {
+		for it.Next() {
+			list = append(list, element{
+				Ident:      it.Ident(),
+				Offset:     it.Offset(),
+				Header:     it.Header(),
+				Content:    it.Content(),
+				Indefinite: it.Indefinite(),
+			})
+			if it.Ident().Type() == Constructed {
+				walk(it.Descend())
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("scan: unexpected error! %s", err)
+		}
+	}
+	walk(it)
+
+	want := []element{
+		{Ident: Sequence, Offset: 0, Header: 2, Content: selectBytes[2:23], Indefinite: true},
+		{Ident: Int, Offset: 2, Header: 2, Content: []byte{0x01}},
+		{Ident: UTF8String, Offset: 5, Header: 2, Content: []byte{0x61}},
+		{Ident: Sequence, Offset: 8, Header: 2, Content: selectBytes[10:23]},
+		{Ident: Bool, Offset: 10, Header: 2, Content: []byte{0xff}},
+		{Ident: NewConstructed(3).Context(), Offset: 13, Header: 2, Content: selectBytes[15:23]},
+		{Ident: Int, Offset: 15, Header: 2, Content: []byte{0x02}},
+		{Ident: Sequence, Offset: 18, Header: 2, Content: selectBytes[20:23]},
+		{Ident: Int, Offset: 20, Header: 2, Content: []byte{0x03}},
+		{Ident: Int, Offset: 25, Header: 2, Content: []byte{0x04}},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("scan: length mismatched! want %d, got %d", len(want), len(list))
+	}
+	for i, w := range want {
+		g := list[i]
+		if g.Ident != w.Ident || g.Offset != w.Offset || g.Header != w.Header || g.Indefinite != w.Indefinite || !bytes.Equal(g.Content, w.Content) {
+			t.Errorf("scan: element %d mismatched! want %+v, got %+v", i, w, g)
+		}
+	}
+}
+
+func testIteratorErrors(t *testing.T) {
+	it := NewIterator([]byte{0x02, 0x01, 0x01, 0x30, 0x05, 0x02})
+	if !it.Next() || it.Next() {
+		t.Fatalf("errors: truncated element accepted")
+	}
+	var se *SyntaxError
+	if err := it.Err(); !errors.As(err, &se) || se.Offset != 3 {
+		t.Errorf("errors: expected *SyntaxError at offset 3, got %T (%v)", err, err)
+	}
+
+	it = NewIterator([]byte{0x02, 0x01, 0x01})
+	it.Next()
+	child := it.Descend()
+	if child.Next() || !errors.Is(child.Err(), ErrConstructed) {
+		t.Errorf("errors: primitive element descended (%v)", child.Err())
+	}
+}
+
+func testIteratorAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		it := NewIterator(selectBytes)
+		for it.Next() {
+			if it.Ident().Type() != Constructed {
+				continue
+			}
+			child := it.Descend()
+			for child.Next() {
+				_ = child.Content()
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("allocs: iterator should not allocate (got %.0f)", allocs)
+	}
+}
@@ -0,0 +1,849 @@
+package ber
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var marshaltype = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// MarshalJER gives the JSON encoding of v following the ASN.1 JSON Encoding
+// Rules (X.697). The Go types of v are mapped to JSON values as follows:
+//
+//	bool                          true or false
+//	integers, *big.Int            number
+//	floats                        number or "INF", "-INF", "NaN", "-0"
+//	string                        string
+//	[]byte                        string of hexadecimal digits
+//	Bits, named bits              {"value": hexadecimal digits, "length": number of bits}
+//	OID                           string with the dotted form of the OID
+//	time.Time                     string with the GeneralizedTime form of the time
+//	struct                        object with a member per field
+//	slice, array                  array
+//	map                           object
+//	nil pointer, nil interface    null
+//	CHOICE                        object with a member named by the type of the alternative
+//
+// A member of an object is named by the json tag of its field or by the name
+// of the field. Raw, Tagged and the types implementing Marshaler have no JER
+// encoding: they are given the schema-less form written by BERToJSON.
+func MarshalJER(v interface{}) ([]byte, error) {
+	var j jerEncoder
+	if err := j.encode(reflect.ValueOf(v), nil, false); err != nil {
+		return nil, rootPath(valueError(err), reflect.TypeOf(v))
+	}
+	return j.buf.Bytes(), nil
+}
+
+// UnmarshalJER decodes the JSON encoding of a value written by MarshalJER in
+// the value pointed to by v.
+func UnmarshalJER(data []byte, v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("jer: non nil pointer expected (got %T)", v)
+	}
+	x, err := readJSON(data)
+	if err != nil {
+		return err
+	}
+	if err := decodeJER(val.Elem(), x, nil, false); err != nil {
+		return rootPath(valueError(err), val.Type())
+	}
+	return nil
+}
+
+// BERToJSON converts the element encoded in buf to JSON. When schema is nil,
+// the element is written in a schema-less form keeping its identifier and its
+// contents:
+//
+//	{"class": "UNIVERSAL", "tag": 2, "value": "05"}
+//	{"class": "CONTEXT", "tag": 1, "value": [...]}
+//
+// where value is a string of hexadecimal digits for a primitive element and an
+// array with the elements of a constructed element. Otherwise, the element is
+// decoded in a value of the type of schema (eg: (*Certificate)(nil)) that is
+// written with MarshalJER.
+func BERToJSON(buf []byte, schema interface{}) ([]byte, error) {
+	if schema == nil {
+		n, err := Parse(buf)
+		if err != nil {
+			return nil, err
+		}
+		var j jerEncoder
+		j.encodeNode(n)
+		return j.buf.Bytes(), nil
+	}
+	var (
+		val = reflect.New(schemaType(schema))
+		dec = NewDecoder(buf)
+	)
+	if err := dec.Decode(val.Interface()); err != nil {
+		return nil, err
+	}
+	if !dec.Empty() {
+		err := fmt.Errorf("%d bytes after element", dec.Len())
+		dec.wrapError(&err, dec.offset)
+		return nil, err
+	}
+	return MarshalJER(val.Elem().Interface())
+}
+
+// JSONToBER converts JSON written by BERToJSON with the same schema back to
+// BER.
+func JSONToBER(data []byte, schema interface{}) ([]byte, error) {
+	if schema == nil {
+		x, err := readJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		n, err := jsonNode(x)
+		if err != nil {
+			return nil, valueError(err)
+		}
+		return n.Encode()
+	}
+	val := reflect.New(schemaType(schema))
+	if err := UnmarshalJER(data, val.Interface()); err != nil {
+		return nil, err
+	}
+	var e Encoder
+	if err := e.Encode(val.Elem().Interface()); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func schemaType(schema interface{}) reflect.Type {
+	typ := reflect.TypeOf(schema)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+func readJSON(data []byte) (interface{}, error) {
+	var (
+		x   interface{}
+		dec = json.NewDecoder(bytes.NewReader(data))
+	)
+	dec.UseNumber()
+	if err := dec.Decode(&x); err != nil {
+		return nil, fmt.Errorf("jer: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("jer: data after JSON value")
+	}
+	return x, nil
+}
+
+// valueError gives the error describing a value that can not be converted from
+// or to a textual encoding.
+func valueError(err error) error {
+	if err == nil || isTyped(err) {
+		return err
+	}
+	return &StructuralError{
+		Offset: -1,
+		Err:    err,
+	}
+}
+
+type jerEncoder struct {
+	buf bytes.Buffer
+}
+
+func (j *jerEncoder) encode(val reflect.Value, names []string, named bool) error {
+	if !val.IsValid() {
+		j.buf.WriteString("null")
+		return nil
+	}
+	if !named {
+		names, named = registeredNamedBits(val.Type())
+	}
+	if named {
+		bs, err := namedBitString(val, names)
+		if err != nil {
+			return err
+		}
+		j.encodeBitString(bs)
+		return nil
+	}
+	switch typ := val.Type(); {
+	case typ == rawtype:
+		return j.encodeRaw(val.Bytes())
+	case typ == taggedtype:
+		var e Encoder
+		if err := e.encode(val.Interface(), 0); err != nil {
+			return err
+		}
+		return j.encodeRaw(e.Bytes())
+	case typ == timetype:
+		t := val.Interface().(time.Time)
+		j.encodeString(t.UTC().Format(patGeneralTimeZ))
+		return nil
+	case typ == bitstringtype:
+		j.encodeBitString(val.Interface().(Bits))
+		return nil
+	case typ == bigtype:
+		x := val.Interface().(big.Int)
+		j.buf.WriteString(x.String())
+		return nil
+	case typ == oidtype:
+		j.encodeString(val.String())
+		return nil
+	case val.Kind() != reflect.Ptr && val.Kind() != reflect.Interface && val.CanInterface() && typ.Implements(marshaltype):
+		buf, err := val.Interface().(Marshaler).Marshal()
+		if err != nil {
+			return err
+		}
+		return j.encodeRaw(buf)
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		j.buf.WriteString(strconv.FormatBool(val.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		j.buf.WriteString(strconv.FormatInt(val.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		j.buf.WriteString(strconv.FormatUint(val.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		j.encodeFloat(val.Float())
+	case reflect.String:
+		j.encodeString(val.String())
+	case reflect.Slice, reflect.Array:
+		if val.Type() == bytestype {
+			j.encodeString(strings.ToUpper(hex.EncodeToString(val.Bytes())))
+			break
+		}
+		if val.Kind() == reflect.Slice && val.IsNil() {
+			j.buf.WriteString("[]")
+			break
+		}
+		return j.encodeArray(val)
+	case reflect.Map:
+		return j.encodeMap(val)
+	case reflect.Struct:
+		return j.encodeStruct(val)
+	case reflect.Ptr:
+		if val.IsNil() {
+			j.buf.WriteString("null")
+			break
+		}
+		return j.encode(val.Elem(), nil, false)
+	case reflect.Interface:
+		if val.IsNil() {
+			j.buf.WriteString("null")
+			break
+		}
+		if c, ok := registeredChoice(val.Type()); ok {
+			return j.encodeChoice(val, c)
+		}
+		return j.encode(val.Elem(), nil, false)
+	default:
+		return fmt.Errorf("%s can not be encoded in JSON", val.Type())
+	}
+	return nil
+}
+
+func (j *jerEncoder) encodeString(str string) {
+	buf, _ := json.Marshal(str)
+	j.buf.Write(buf)
+}
+
+func (j *jerEncoder) encodeFloat(f float64) {
+	switch {
+	case math.IsInf(f, 1):
+		j.encodeString("INF")
+	case math.IsInf(f, -1):
+		j.encodeString("-INF")
+	case math.IsNaN(f):
+		j.encodeString("NaN")
+	case f == 0 && math.Signbit(f):
+		j.encodeString("-0")
+	default:
+		j.buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+}
+
+func (j *jerEncoder) encodeBitString(bs Bits) {
+	j.buf.WriteString(`{"value":`)
+	j.encodeString(strings.ToUpper(hex.EncodeToString(bs.Bytes)))
+	j.buf.WriteString(`,"length":`)
+	j.buf.WriteString(strconv.Itoa(bs.BitLength))
+	j.buf.WriteString("}")
+}
+
+func (j *jerEncoder) encodeArray(val reflect.Value) error {
+	j.buf.WriteString("[")
+	for i := 0; i < val.Len(); i++ {
+		if i > 0 {
+			j.buf.WriteString(",")
+		}
+		if err := j.encode(val.Index(i), nil, false); err != nil {
+			return prefixPath(valueError(err), indexPath(i))
+		}
+	}
+	j.buf.WriteString("]")
+	return nil
+}
+
+func (j *jerEncoder) encodeMap(val reflect.Value) error {
+	if val.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%s: map keys should be strings", val.Type())
+	}
+	keys := val.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	j.buf.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			j.buf.WriteString(",")
+		}
+		j.encodeString(k.String())
+		j.buf.WriteString(":")
+		if err := j.encode(val.MapIndex(k), nil, false); err != nil {
+			return prefixPath(valueError(err), keyPath(k))
+		}
+	}
+	j.buf.WriteString("}")
+	return nil
+}
+
+func (j *jerEncoder) encodeStruct(val reflect.Value) error {
+	var (
+		typ   = val.Type()
+		tags  = structTags(typ, Implicit)
+		count int
+	)
+	j.buf.WriteString("{")
+	for i := 0; i < val.NumField(); i++ {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident {
+			continue
+		}
+		if (ft.opts.optional && isNil(f)) || (ft.omit && f.IsZero()) {
+			continue
+		}
+		if count > 0 {
+			j.buf.WriteString(",")
+		}
+		count++
+		j.encodeString(jerName(sf))
+		j.buf.WriteString(":")
+		names, named := namedBitsForField(sf)
+		if err := j.encode(f, names, named); err != nil {
+			return prefixPath(valueError(err), sf.Name)
+		}
+	}
+	j.buf.WriteString("}")
+	return nil
+}
+
+func (j *jerEncoder) encodeChoice(val reflect.Value, c *choice) error {
+	alt := val.Elem()
+	if _, ok := c.ident(alt.Type()); !ok {
+		return fmt.Errorf("choice: %s is not an alternative of %s", alt.Type(), val.Type())
+	}
+	j.buf.WriteString("{")
+	j.encodeString(choiceName(alt.Type()))
+	j.buf.WriteString(":")
+	if err := j.encode(alt, nil, false); err != nil {
+		return err
+	}
+	j.buf.WriteString("}")
+	return nil
+}
+
+func (j *jerEncoder) encodeRaw(buf []byte) error {
+	n, err := Parse(buf)
+	if err != nil {
+		return err
+	}
+	j.encodeNode(n)
+	return nil
+}
+
+// encodeNode writes the schema-less form of n.
+func (j *jerEncoder) encodeNode(n *Node) {
+	j.buf.WriteString(`{"class":`)
+	j.encodeString(classNames[n.Ident.Class()])
+	j.buf.WriteString(`,"tag":`)
+	j.buf.WriteString(strconv.FormatUint(uint64(n.Ident.Tag()), 10))
+	j.buf.WriteString(`,"value":`)
+	if n.Constructed() {
+		j.buf.WriteString("[")
+		for i, c := range n.Children {
+			if i > 0 {
+				j.buf.WriteString(",")
+			}
+			j.encodeNode(c)
+		}
+		j.buf.WriteString("]")
+	} else {
+		j.encodeString(strings.ToUpper(hex.EncodeToString(n.Content)))
+	}
+	j.buf.WriteString("}")
+}
+
+func jerName(sf reflect.StructField) string {
+	str := sf.Tag.Get("json")
+	if i := strings.Index(str, ","); i >= 0 {
+		str = str[:i]
+	}
+	if str == "" || str == "-" {
+		return sf.Name
+	}
+	return str
+}
+
+// jsonNode gives the node described by the schema-less form x.
+func jsonNode(x interface{}) (*Node, error) {
+	obj, ok := x.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("element: object expected (got %s)", jsonType(x))
+	}
+	var class uint8
+	switch str := obj["class"].(type) {
+	case nil:
+	case string:
+		x := indexName(classNames, strings.ToUpper(str))
+		if x < 0 {
+			return nil, fmt.Errorf("%s: unknown class", str)
+		}
+		class = uint8(x)
+	default:
+		return nil, fmt.Errorf("class: string expected (got %s)", jsonType(str))
+	}
+	num, ok := obj["tag"].(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("tag: number expected (got %s)", jsonType(obj["tag"]))
+	}
+	tag, err := strconv.ParseUint(string(num), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("tag: %w", err)
+	}
+	id := retag(0, class, uint32(tag))
+	switch value := obj["value"].(type) {
+	case string:
+		buf, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		return NewPrimitiveNode(id, buf), nil
+	case []interface{}:
+		n := NewConstructedNode(id)
+		for i, x := range value {
+			c, err := jsonNode(x)
+			if err != nil {
+				return nil, prefixPath(valueError(err), indexPath(i))
+			}
+			n.Append(c)
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("value: string or array expected (got %s)", jsonType(value))
+	}
+}
+
+// isJSONNode reports whether the object x has the schema-less form of an
+// element.
+func isJSONNode(x interface{}) bool {
+	obj, ok := x.(map[string]interface{})
+	if !ok || len(obj) != 3 {
+		return false
+	}
+	for _, k := range []string{"class", "tag", "value"} {
+		if _, ok := obj[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonType(x interface{}) string {
+	switch x.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func typeError(x interface{}, typ reflect.Type) error {
+	return fmt.Errorf("%s can not be decoded into %s", jsonType(x), typ)
+}
+
+func decodeJER(val reflect.Value, x interface{}, names []string, named bool) error {
+	if !named {
+		names, named = registeredNamedBits(val.Type())
+	}
+	if named {
+		bs, err := jsonBitString(x)
+		if err != nil {
+			return err
+		}
+		return setNamedBits(val, names, bs)
+	}
+	switch typ := val.Type(); {
+	case typ == rawtype:
+		buf, err := jsonBytes(x)
+		if err == nil {
+			val.SetBytes(buf)
+		}
+		return err
+	case typ == taggedtype:
+		buf, err := jsonBytes(x)
+		if err != nil {
+			return err
+		}
+		return NewDecoder(buf).Decode(val.Addr().Interface())
+	case typ == timetype:
+		str, ok := x.(string)
+		if !ok {
+			return typeError(x, typ)
+		}
+		t, err := time.Parse(patGeneralTimeParse, str)
+		if err != nil {
+			t, err = time.Parse(patUniversTimeParse, str)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: invalid time", str)
+		}
+		val.Set(reflect.ValueOf(t.UTC()))
+		return nil
+	case typ == bitstringtype:
+		bs, err := jsonBitString(x)
+		if err == nil {
+			val.Set(reflect.ValueOf(bs))
+		}
+		return err
+	case typ == bigtype:
+		num, ok := x.(json.Number)
+		if !ok {
+			return typeError(x, typ)
+		}
+		b, ok := new(big.Int).SetString(string(num), 10)
+		if !ok {
+			return fmt.Errorf("%s: invalid integer", num)
+		}
+		val.Addr().Interface().(*big.Int).Set(b)
+		return nil
+	case val.Kind() != reflect.Ptr && val.Kind() != reflect.Interface && reflect.PtrTo(typ).Implements(unmarshaltype):
+		buf, err := jsonBytes(x)
+		if err != nil {
+			return err
+		}
+		return val.Addr().Interface().(Unmarshaler).Unmarshal(buf)
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return typeError(x, val.Type())
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, ok := x.(json.Number)
+		if !ok {
+			return typeError(x, val.Type())
+		}
+		i, err := strconv.ParseInt(string(num), 10, 64)
+		if err != nil || val.OverflowInt(i) {
+			return fmt.Errorf("%s: %w", num, ErrOverflow)
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, ok := x.(json.Number)
+		if !ok {
+			return typeError(x, val.Type())
+		}
+		i, err := strconv.ParseUint(string(num), 10, 64)
+		if err != nil || val.OverflowUint(i) {
+			return fmt.Errorf("%s: %w", num, ErrOverflow)
+		}
+		val.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := jsonFloat(x)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.String:
+		str, ok := x.(string)
+		if !ok {
+			return typeError(x, val.Type())
+		}
+		val.SetString(str)
+	case reflect.Slice:
+		if val.Type() == bytestype {
+			str, ok := x.(string)
+			if !ok {
+				return typeError(x, val.Type())
+			}
+			buf, err := hex.DecodeString(str)
+			if err != nil {
+				return err
+			}
+			val.SetBytes(buf)
+			break
+		}
+		list, ok := x.([]interface{})
+		if !ok {
+			return typeError(x, val.Type())
+		}
+		val.Set(reflect.MakeSlice(val.Type(), len(list), len(list)))
+		return decodeJERArray(val, list)
+	case reflect.Array:
+		list, ok := x.([]interface{})
+		if !ok {
+			return typeError(x, val.Type())
+		}
+		if len(list) != val.Len() {
+			return fmt.Errorf("%d elements can not be decoded into %s", len(list), val.Type())
+		}
+		return decodeJERArray(val, list)
+	case reflect.Map:
+		return decodeJERMap(val, x)
+	case reflect.Struct:
+		return decodeJERStruct(val, x)
+	case reflect.Ptr:
+		if x == nil {
+			val.Set(reflect.Zero(val.Type()))
+			break
+		}
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		return decodeJER(val.Elem(), x, nil, false)
+	case reflect.Interface:
+		if x == nil {
+			val.Set(reflect.Zero(val.Type()))
+			break
+		}
+		if c, ok := registeredChoice(val.Type()); ok {
+			return decodeJERChoice(val, x, c)
+		}
+		if val.NumMethod() > 0 {
+			return fmt.Errorf("no alternative registered for %s", val.Type())
+		}
+		v, err := jsonGeneric(x)
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			val.Set(reflect.ValueOf(v))
+		}
+	default:
+		return fmt.Errorf("JSON can not be decoded into %s", val.Type())
+	}
+	return nil
+}
+
+func decodeJERArray(val reflect.Value, list []interface{}) error {
+	for i, x := range list {
+		if err := decodeJER(val.Index(i), x, nil, false); err != nil {
+			return prefixPath(valueError(err), indexPath(i))
+		}
+	}
+	return nil
+}
+
+func decodeJERMap(val reflect.Value, x interface{}) error {
+	obj, ok := x.(map[string]interface{})
+	if !ok {
+		return typeError(x, val.Type())
+	}
+	typ := val.Type()
+	if typ.Key().Kind() != reflect.String {
+		return fmt.Errorf("%s: map keys should be strings", typ)
+	}
+	if val.IsNil() {
+		val.Set(reflect.MakeMapWithSize(typ, len(obj)))
+	}
+	for k, x := range obj {
+		var (
+			key = reflect.ValueOf(k).Convert(typ.Key())
+			v   = reflect.New(typ.Elem()).Elem()
+		)
+		if err := decodeJER(v, x, nil, false); err != nil {
+			return prefixPath(valueError(err), keyPath(key))
+		}
+		val.SetMapIndex(key, v)
+	}
+	return nil
+}
+
+func decodeJERStruct(val reflect.Value, x interface{}) error {
+	obj, ok := x.(map[string]interface{})
+	if !ok {
+		return typeError(x, val.Type())
+	}
+	var (
+		typ  = val.Type()
+		tags = structTags(typ, Implicit)
+	)
+	for i := 0; i < val.NumField(); i++ {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident || !f.CanSet() {
+			continue
+		}
+		x, ok := obj[jerName(sf)]
+		if !ok {
+			switch {
+			case ft.opts.hasDef:
+				def, err := defaultValue(f.Type(), ft.opts.def)
+				if err != nil {
+					return prefixPath(valueError(err), sf.Name)
+				}
+				f.Set(def)
+			case !ft.opts.optional && !ft.omit:
+				return prefixPath(valueError(fmt.Errorf("missing member %s", jerName(sf))), sf.Name)
+			}
+			continue
+		}
+		names, named := namedBitsForField(sf)
+		if err := decodeJER(f, x, names, named); err != nil {
+			return prefixPath(valueError(err), sf.Name)
+		}
+	}
+	return nil
+}
+
+func decodeJERChoice(val reflect.Value, x interface{}, c *choice) error {
+	obj, ok := x.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return fmt.Errorf("choice: object with one member expected for %s", val.Type())
+	}
+	for name, x := range obj {
+		typ, ok := c.named(name)
+		if !ok {
+			return fmt.Errorf("choice: %s is not an alternative of %s", name, val.Type())
+		}
+		alt := reflect.New(typ).Elem()
+		if err := decodeJER(alt, x, nil, false); err != nil {
+			return err
+		}
+		val.Set(alt)
+	}
+	return nil
+}
+
+// jsonBytes gives the encoding of the element described by the schema-less
+// form x.
+func jsonBytes(x interface{}) ([]byte, error) {
+	n, err := jsonNode(x)
+	if err != nil {
+		return nil, err
+	}
+	return n.Encode()
+}
+
+func jsonBitString(x interface{}) (Bits, error) {
+	var bs Bits
+	obj, ok := x.(map[string]interface{})
+	if !ok {
+		return bs, typeError(x, bitstringtype)
+	}
+	str, _ := obj["value"].(string)
+	buf, err := hex.DecodeString(str)
+	if err != nil {
+		return bs, fmt.Errorf("bit string: %w", err)
+	}
+	num, _ := obj["length"].(json.Number)
+	size, err := strconv.Atoi(string(num))
+	if err != nil || size < 0 || size > len(buf)*8 || size <= len(buf)*8-8 && len(buf) > 0 {
+		return bs, fmt.Errorf("bit string: invalid length %q for %d bytes", num, len(buf))
+	}
+	bs.Bytes, bs.BitLength = buf, size
+	return bs, nil
+}
+
+func jsonFloat(x interface{}) (float64, error) {
+	switch x := x.(type) {
+	case json.Number:
+		return strconv.ParseFloat(string(x), 64)
+	case string:
+		switch x {
+		case "INF":
+			return math.Inf(1), nil
+		case "-INF":
+			return math.Inf(-1), nil
+		case "NaN":
+			return math.NaN(), nil
+		case "-0":
+			return math.Copysign(0, -1), nil
+		}
+	}
+	return 0, fmt.Errorf("%v: invalid real", x)
+}
+
+// jsonGeneric gives the Go value of x decoded into an empty interface: the
+// schema-less form of an element is decoded as by Decoder.Decode.
+func jsonGeneric(x interface{}) (interface{}, error) {
+	switch x := x.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		if b, ok := new(big.Int).SetString(string(x), 10); ok {
+			return b, nil
+		}
+		return x.Float64()
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i := range x {
+			v, err := jsonGeneric(x[i])
+			if err != nil {
+				return nil, prefixPath(valueError(err), indexPath(i))
+			}
+			list[i] = v
+		}
+		return list, nil
+	case map[string]interface{}:
+		if !isJSONNode(x) {
+			obj := make(map[string]interface{}, len(x))
+			for k := range x {
+				v, err := jsonGeneric(x[k])
+				if err != nil {
+					return nil, prefixPath(valueError(err), keyPath(reflect.ValueOf(k)))
+				}
+				obj[k] = v
+			}
+			return obj, nil
+		}
+		buf, err := jsonBytes(x)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		return v, NewDecoder(buf).Decode(&v)
+	default:
+		return x, nil
+	}
+}
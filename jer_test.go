@@ -0,0 +1,197 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type jerSample struct {
+	Version int64 `json:"version"`
+	Name    string
+	Serial  *big.Int
+	Ratio   float64
+	Data    []byte
+	Usage   keyUsage
+	Bits    Bits
+	Algo    OID
+	When    time.Time
+	Shape   choiceShape
+	Tags    []string
+	Extra   *string `ber:"optional"`
+	Skip    int     `ber:"-"`
+}
+
+func TestJER(t *testing.T) {
+	t.Run("marshal", testMarshalJER)
+	t.Run("unmarshal", testUnmarshalJER)
+	t.Run("reals", testJERReals)
+	t.Run("schema", testJERSchema)
+	t.Run("schemaless", testJERSchemaless)
+	t.Run("errors", testJERErrors)
+}
+
+var (
+	jerValue = jerSample{
+		Version: 2,
+		Name:    "test",
+		Serial:  bigInt("123456789012345678901234567890"),
+		Ratio:   0.5,
+		Data:    []byte{0xde, 0xad},
+		Usage:   digitalSignature | keyCertSign,
+		Bits:    Bits{Bytes: []byte{0xa0}, BitLength: 3},
+		Algo:    OID("1.2.840.113549"),
+		When:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Shape:   &choiceRect{Width: 3, Height: 4},
+		Tags:    []string{"a", "b"},
+	}
+	jerText = `{"version":2,"Name":"test","Serial":123456789012345678901234567890,` +
+		`"Ratio":0.5,"Data":"DEAD","Usage":{"value":"84","length":6},` +
+		`"Bits":{"value":"A0","length":3},"Algo":"1.2.840.113549",` +
+		`"When":"20210304050607Z","Shape":{"choiceRect":{"Width":3,"Height":4}},` +
+		`"Tags":["a","b"]}`
+)
+
+func testMarshalJER(t *testing.T) {
+	got, err := MarshalJER(jerValue)
+	if err != nil {
+		t.Fatalf("marshal: fail to marshal value! %s", err)
+	}
+	if string(got) != jerText {
+		t.Errorf("marshal: json mismatched!\nwant %s\ngot  %s", jerText, got)
+	}
+}
+
+func testUnmarshalJER(t *testing.T) {
+	var got jerSample
+	if err := UnmarshalJER([]byte(jerText), &got); err != nil {
+		t.Fatalf("unmarshal: fail to unmarshal value! %s", err)
+	}
+	if !reflect.DeepEqual(got, jerValue) {
+		t.Errorf("unmarshal: value mismatched! want %+v, got %+v", jerValue, got)
+	}
+
+	var v interface{}
+	if err := UnmarshalJER([]byte(`{"n":[1,1.5,{"class":"UNIVERSAL","tag":6,"value":"2A03"}]}`), &v); err != nil {
+		t.Fatalf("unmarshal: fail to unmarshal generic value! %s", err)
+	}
+	want := map[string]interface{}{
+		"n": []interface{}{int64(1), 1.5, OID("1.2.3")},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("unmarshal: generic value mismatched! want %#v, got %#v", want, v)
+	}
+}
+
+func testJERReals(t *testing.T) {
+	data := []struct {
+		Value float64
+		Want  string
+	}{
+		{Value: 1.25, Want: "1.25"},
+		{Value: math.Inf(1), Want: `"INF"`},
+		{Value: math.Inf(-1), Want: `"-INF"`},
+		{Value: math.NaN(), Want: `"NaN"`},
+		{Value: math.Copysign(0, -1), Want: `"-0"`},
+	}
+	for _, d := range data {
+		got, err := MarshalJER(d.Value)
+		if err != nil {
+			t.Errorf("reals: fail to marshal %f! %s", d.Value, err)
+			continue
+		}
+		if string(got) != d.Want {
+			t.Errorf("reals: json mismatched! want %s, got %s", d.Want, got)
+			continue
+		}
+		var f float64
+		if err := UnmarshalJER(got, &f); err != nil {
+			t.Errorf("reals: fail to unmarshal %s! %s", got, err)
+			continue
+		}
+		if math.Float64bits(f) != math.Float64bits(d.Value) && !(math.IsNaN(f) && math.IsNaN(d.Value)) {
+			t.Errorf("reals: value mismatched! want %f, got %f", d.Value, f)
+		}
+	}
+}
+
+func testJERSchema(t *testing.T) {
+	var e Encoder
+	if err := e.Encode(jerValue); err != nil {
+		t.Fatalf("schema: fail to encode value! %s", err)
+	}
+	str, err := BERToJSON(e.Bytes(), (*jerSample)(nil))
+	if err != nil {
+		t.Fatalf("schema: fail to convert to JSON! %s", err)
+	}
+	if string(str) != jerText {
+		t.Errorf("schema: json mismatched!\nwant %s\ngot  %s", jerText, str)
+	}
+	buf, err := JSONToBER(str, (*jerSample)(nil))
+	if err != nil {
+		t.Fatalf("schema: fail to convert to BER! %s", err)
+	}
+	if !bytes.Equal(buf, e.Bytes()) {
+		t.Errorf("schema: bytes mismatched! want %x, got %x", e.Bytes(), buf)
+	}
+	trailing := append(append([]byte{}, e.Bytes()...), 0x05, 0x00)
+	if _, err := BERToJSON(trailing, (*jerSample)(nil)); err == nil {
+		t.Errorf("schema: trailing bytes accepted")
+	}
+}
+
+func testJERSchemaless(t *testing.T) {
+	var (
+		buf  = []byte{0x30, 0x08, 0x02, 0x01, 0x05, 0xa1, 0x03, 0x04, 0x01, 0xff}
+		want = `{"class":"UNIVERSAL","tag":16,"value":[` +
+			`{"class":"UNIVERSAL","tag":2,"value":"05"},` +
+			`{"class":"CONTEXT","tag":1,"value":[{"class":"UNIVERSAL","tag":4,"value":"FF"}]}]}`
+	)
+	str, err := BERToJSON(buf, nil)
+	if err != nil {
+		t.Fatalf("schemaless: fail to convert to JSON! %s", err)
+	}
+	if string(str) != want {
+		t.Errorf("schemaless: json mismatched!\nwant %s\ngot  %s", want, str)
+	}
+	got, err := JSONToBER(str, nil)
+	if err != nil {
+		t.Fatalf("schemaless: fail to convert to BER! %s", err)
+	}
+	if !bytes.Equal(got, buf) {
+		t.Errorf("schemaless: bytes mismatched! want %x, got %x", buf, got)
+	}
+}
+
+func testJERErrors(t *testing.T) {
+	data := []struct {
+		Name  string
+		Input string
+		Value interface{}
+		Err   error
+	}{
+		{Name: "overflow", Input: "300", Value: new(int8), Err: ErrOverflow},
+		{Name: "type", Input: `"1"`, Value: new(int)},
+		{Name: "missing", Input: `{"Name":"test"}`, Value: new(jerSample)},
+		{Name: "choice", Input: `{"Shape":{"unknown":1}}`, Value: new(struct{ Shape choiceShape })},
+		{Name: "trailing", Input: "1 2", Value: new(int)},
+		{Name: "bits", Input: `{"value":"FF","length":9}`, Value: new(Bits)},
+	}
+	for _, d := range data {
+		err := UnmarshalJER([]byte(d.Input), d.Value)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", d.Name)
+			continue
+		}
+		if d.Err != nil && !errors.Is(err, d.Err) {
+			t.Errorf("%s: unexpected error! want %s, got %s", d.Name, d.Err, err)
+		}
+	}
+	if _, err := JSONToBER([]byte(`{"class":"NONE","tag":1,"value":""}`), nil); err == nil {
+		t.Errorf("class: expected error, got nil")
+	}
+}
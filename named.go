@@ -0,0 +1,189 @@
+package ber
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var namedBits = struct {
+	sync.RWMutex
+	types map[reflect.Type][]string
+}{
+	types: make(map[reflect.Type][]string),
+}
+
+// RegisterNamedBits declares that values of the type of v are encoded as a
+// BIT STRING with a named bit list. v should be an integer, where bit i of the
+// BIT STRING is mapped to 1<<i, or a slice of strings, where bit i is mapped
+// to names[i].
+func RegisterNamedBits(v interface{}, names ...string) {
+	typ := reflect.TypeOf(v)
+	if !isNamedBitsKind(typ) {
+		panic(fmt.Sprintf("%s can not be used for named bits", typ))
+	}
+	namedBits.Lock()
+	defer namedBits.Unlock()
+	namedBits.types[typ] = append([]string{}, names...)
+}
+
+func registeredNamedBits(typ reflect.Type) ([]string, bool) {
+	namedBits.RLock()
+	defer namedBits.RUnlock()
+	names, ok := namedBits.types[typ]
+	return names, ok
+}
+
+// namedBitsForField reports whether a field should be encoded as a BIT STRING
+// with a named bit list and gives the names of its bits. The names are given by
+// the names option of the field tag (names:first|second|...) or by the type of
+// the field if it has been registered with RegisterNamedBits. An integer field
+// tagged as bitstring is also treated as a named bit list.
+func namedBitsForField(sf reflect.StructField) ([]string, bool) {
+	if !isNamedBitsKind(sf.Type) {
+		return nil, false
+	}
+	var bitstring bool
+	for _, str := range strings.Split(sf.Tag.Get("ber"), ",") {
+		if strings.HasPrefix(str, "names:") {
+			str = strings.TrimSpace(strings.TrimPrefix(str, "names:"))
+			return strings.Split(str, "|"), true
+		}
+		bitstring = bitstring || str == "bitstring"
+	}
+	if names, ok := registeredNamedBits(sf.Type); ok {
+		return names, ok
+	}
+	return nil, bitstring && isInteger(sf.Type.Kind())
+}
+
+func isNamedBitsKind(typ reflect.Type) bool {
+	if typ == nil {
+		return false
+	}
+	return isInteger(typ.Kind()) || (typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.String)
+}
+
+func isInteger(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// encodeNamedBits encodes val as a BIT STRING with a named bit list. Trailing
+// zero bits are always removed as required by DER (X.690 11.2.2).
+func (e *Encoder) encodeNamedBits(val reflect.Value, names []string, tag Ident) error {
+	if tag.Class() == Universal {
+		tag = BitString
+	}
+	bs, err := namedBitString(val, names)
+	if err != nil {
+		return err
+	}
+	return e.EncodeBitStringWithIdent(bs, tag)
+}
+
+// namedBitString gives the BIT STRING with a named bit list for the value val.
+func namedBitString(val reflect.Value, names []string) (Bits, error) {
+	var bs Bits
+	switch k := val.Kind(); {
+	case k >= reflect.Int && k <= reflect.Int64:
+		if val.Int() < 0 {
+			return bs, fmt.Errorf("named bits: negative value %d", val.Int())
+		}
+		setBits(&bs, uint64(val.Int()))
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		setBits(&bs, val.Uint())
+	case k == reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			str := val.Index(i).String()
+			x := indexName(names, str)
+			if x < 0 {
+				return bs, fmt.Errorf("named bits: %s: unknown name", str)
+			}
+			bs.Set(x, true)
+		}
+	default:
+		return bs, fmt.Errorf("named bits: can not be encoded from %s", k)
+	}
+	return bs, nil
+}
+
+func (d *Decoder) decodeNamedBits(val reflect.Value, names []string) error {
+	offset := d.offset
+	bs, err := d.DecodeBitString()
+	if err != nil {
+		return err
+	}
+	if err := setNamedBits(val, names, bs); err != nil {
+		return d.structuralError(offset, BitString, err)
+	}
+	return nil
+}
+
+// setNamedBits sets val from the BIT STRING bs with a named bit list.
+func setNamedBits(val reflect.Value, names []string, bs Bits) error {
+	switch k := val.Kind(); {
+	case isInteger(k):
+		var (
+			x    uint64
+			size = val.Type().Bits()
+		)
+		if k <= reflect.Int64 {
+			size--
+		}
+		for i := 0; i < bs.BitLength; i++ {
+			if bs.At(i) == 0 {
+				continue
+			}
+			if i >= size {
+				return fmt.Errorf("named bits: bit %d overflows %s", i, val.Type())
+			}
+			x |= 1 << uint(i)
+		}
+		if k <= reflect.Int64 {
+			val.SetInt(int64(x))
+		} else {
+			val.SetUint(x)
+		}
+	case k == reflect.Slice:
+		list := reflect.MakeSlice(val.Type(), 0, bs.BitLength)
+		for i := 0; i < bs.BitLength; i++ {
+			if bs.At(i) == 0 {
+				continue
+			}
+			if i >= len(names) {
+				return fmt.Errorf("named bits: bit %d has no name", i)
+			}
+			list = reflect.Append(list, reflect.ValueOf(names[i]).Convert(val.Type().Elem()))
+		}
+		val.Set(list)
+	default:
+		return fmt.Errorf("named bits: can not be decoded into %s", k)
+	}
+	return nil
+}
+
+func setBits(bs *Bits, x uint64) {
+	for i := 0; x != 0; i++ {
+		if x&1 == 1 {
+			bs.Set(i, true)
+		}
+		x >>= 1
+	}
+}
+
+func indexName(names []string, str string) int {
+	for i := range names {
+		if names[i] == str {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package ber
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type keyUsage uint16
+
+const (
+	digitalSignature keyUsage = 1 << iota
+	nonRepudiation
+	keyEncipherment
+	dataEncipherment
+	keyAgreement
+	keyCertSign
+	crlSign
+	encipherOnly
+	decipherOnly
+)
+
+func init() {
+	RegisterNamedBits(keyUsage(0))
+}
+
+func TestNamedBits(t *testing.T) {
+	type Sample struct {
+		Usage  keyUsage
+		Names  []string `ber:"bitstring,names:read|write|exec"`
+		Flags  uint8    `ber:"bitstring"`
+		Tagged int      `ber:"bitstring,class:0x2,tag:1"`
+	}
+	var (
+		want = Sample{
+			Usage:  digitalSignature | keyCertSign | decipherOnly,
+			Names:  []string{"read", "exec"},
+			Flags:  0,
+			Tagged: 2,
+		}
+		raw = []byte{
+			0x30, 0x10,
+			0x03, 0x03, 0x07, 0x84, 0x80,
+			0x03, 0x02, 0x05, 0xa0,
+			0x03, 0x01, 0x00,
+			0x81, 0x02, 0x06, 0x40,
+		}
+		got Sample
+		e   = Encoder{rules: DER}
+	)
+	if err := e.Encode(want); err != nil {
+		t.Errorf("named bits: fail to encode! %s", err)
+		return
+	}
+	if !bytes.Equal(e.Bytes(), raw) {
+		t.Errorf("named bits: bytes mismatched! want %x, got %x", raw, e.Bytes())
+	}
+	if err := NewDecoder(raw).Decode(&got); err != nil {
+		t.Errorf("named bits: fail to decode! %s", err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("named bits: values mismatched! want %+v, got %+v", want, got)
+	}
+
+	var invalid Encoder
+	if err := invalid.Encode(Sample{Names: []string{"delete"}}); err == nil {
+		t.Errorf("named bits: unknown name should be rejected")
+	}
+	var small struct {
+		Flags uint8 `ber:"bitstring"`
+	}
+	if err := NewDecoder([]byte{0x30, 0x05, 0x03, 0x03, 0x07, 0x00, 0x80}).Decode(&small); err == nil {
+		t.Errorf("named bits: overflow should be rejected")
+	}
+}
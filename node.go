@@ -0,0 +1,282 @@
+package ber
+
+import (
+	"fmt"
+)
+
+// Node is an element of a tree of BER elements decoded without the Go type
+// of the value they encode. Primitive elements have Content and constructed
+// elements have Children.
+type Node struct {
+	Ident Ident
+	// contents of a primitive element. With Parse, it shares the memory of the
+	// input.
+	Content []byte
+	// elements of a constructed element
+	Children []*Node
+
+	// offset of the element and of its contents in the input given to Parse.
+	// Both are -1 for nodes not created by Parse. They are not updated when the
+	// tree is modified.
+	Offset        int
+	ContentOffset int
+	// the element was encoded with the indefinite form
+	Indefinite bool
+
+	parent *Node
+}
+
+// NewPrimitiveNode creates a primitive node with the given contents.
+func NewPrimitiveNode(id Ident, content []byte) *Node {
+	return &Node{
+		Ident:         id.Primitive(),
+		Content:       content,
+		Offset:        -1,
+		ContentOffset: -1,
+	}
+}
+
+// NewConstructedNode creates a constructed node with the given children.
+func NewConstructedNode(id Ident, children ...*Node) *Node {
+	n := &Node{
+		Ident:         id.Constructed(),
+		Offset:        -1,
+		ContentOffset: -1,
+	}
+	n.Append(children...)
+	return n
+}
+
+// Parse decodes the element encoded in buf into a tree of nodes. buf should
+// contain exactly one element.
+func Parse(buf []byte) (*Node, error) {
+	d := Decoder{buf: buf}
+	n, err := d.decodeNode(nil)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Empty() {
+		err = fmt.Errorf("%d bytes after element", d.Len())
+		d.wrapError(&err, d.offset)
+		return nil, err
+	}
+	return n, nil
+}
+
+func (d *Decoder) decodeNode(parent *Node) (_ *Node, err error) {
+	defer d.wrapError(&err, d.offset)
+	n := Node{
+		Offset: d.offset,
+		parent: parent,
+	}
+	id, z, err := d.decodeIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	d.offset += z
+	size, z, err := d.decodeLength()
+	if err != nil {
+		return nil, err
+	}
+	d.offset += z
+	n.Ident = id
+	n.ContentOffset = d.offset
+	n.Indefinite = size == indefinite
+	if id.Type() == Primitive {
+		d.offset += size
+		n.Content = d.buf[n.ContentOffset:d.offset:d.offset]
+		return &n, nil
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.exit()
+	limit := d.limit(size)
+	for d.more(limit) {
+		c, err := d.decodeNode(&n)
+		if err != nil {
+			return nil, prefixPath(err, indexPath(len(n.Children)))
+		}
+		if limit != indefinite && d.offset > limit {
+			return nil, fmt.Errorf("%s: too many bytes consumed to decode element", id)
+		}
+		n.Children = append(n.Children, c)
+	}
+	return &n, d.leave(limit)
+}
+
+// Encode gives the encoding of the tree rooted at n. Lengths are computed from
+// the current contents of the tree and always use the definite form.
+func (n *Node) Encode() ([]byte, error) {
+	var e Encoder
+	if err := e.encodeNode(n); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Decode decodes the element of the tree rooted at n into v. See
+// Decoder.Decode.
+func (n *Node) Decode(v interface{}) error {
+	buf, err := n.Encode()
+	if err != nil {
+		return err
+	}
+	return NewDecoder(buf).Decode(v)
+}
+
+func (e *Encoder) encodeNode(n *Node) error {
+	if n.Ident.Type() == Primitive {
+		if len(n.Children) > 0 {
+			e.err = e.wrapError(fmt.Errorf("primitive node with children"), n.Ident)
+			return e.err
+		}
+		return e.encodeBytes(n.Content, n.Ident)
+	}
+	ex := e.child()
+	for i, c := range n.Children {
+		if err := ex.encodeNode(c); err != nil {
+			e.err = prefixPath(err, indexPath(i))
+			return e.err
+		}
+	}
+	return e.merge(&ex, n.Ident)
+}
+
+// Constructed reports whether n is a constructed element.
+func (n *Node) Constructed() bool {
+	return n.Ident.Type() == Constructed
+}
+
+// Parent gives the node having n as child or nil for the root of a tree.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Len gives the number of children of n.
+func (n *Node) Len() int {
+	return len(n.Children)
+}
+
+// Child gives the i-th child of n or nil if n has no such child.
+func (n *Node) Child(i int) *Node {
+	if i < 0 || i >= len(n.Children) {
+		return nil
+	}
+	return n.Children[i]
+}
+
+// Index gives the position of n in the children of its parent or -1 if n has
+// no parent.
+func (n *Node) Index() int {
+	if n.parent == nil {
+		return -1
+	}
+	for i, c := range n.parent.Children {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find gives the first child of n with the identifier id or nil if there is
+// none.
+func (n *Node) Find(id Ident) *Node {
+	for _, c := range n.Children {
+		if c.Ident == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// FindAll gives the children of n with the identifier id.
+func (n *Node) FindAll(id Ident) []*Node {
+	var list []*Node
+	for _, c := range n.Children {
+		if c.Ident == id {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Walk calls fn for n and for each of its descendants in depth first order.
+// The children of a node are not visited when fn returns false for it.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// SetContent replaces the contents of the primitive node n.
+func (n *Node) SetContent(content []byte) {
+	n.Content = content
+}
+
+// Append adds children at the end of the children of n.
+func (n *Node) Append(children ...*Node) {
+	for _, c := range children {
+		c.Detach()
+		c.parent = n
+	}
+	n.Children = append(n.Children, children...)
+}
+
+// Insert adds children before the i-th child of n. i can be equal to the number
+// of children of n.
+func (n *Node) Insert(i int, children ...*Node) error {
+	if i < 0 || i > len(n.Children) {
+		return fmt.Errorf("insert: index %d out of range [0:%d]", i, len(n.Children))
+	}
+	for _, c := range children {
+		if c.parent == n && c.Index() < i {
+			i--
+		}
+		c.Detach()
+		c.parent = n
+	}
+	list := make([]*Node, 0, len(n.Children)+len(children))
+	list = append(list, n.Children[:i]...)
+	list = append(list, children...)
+	n.Children = append(list, n.Children[i:]...)
+	return nil
+}
+
+// Replace replaces the i-th child of n by c.
+func (n *Node) Replace(i int, c *Node) error {
+	if i < 0 || i >= len(n.Children) {
+		return fmt.Errorf("replace: index %d out of range [0:%d]", i, len(n.Children))
+	}
+	old := n.Children[i]
+	if old == c {
+		return nil
+	}
+	c.Detach()
+	n.Children[old.Index()], c.parent = c, n
+	old.parent = nil
+	return nil
+}
+
+// Remove removes the i-th child of n and returns it.
+func (n *Node) Remove(i int) (*Node, error) {
+	if i < 0 || i >= len(n.Children) {
+		return nil, fmt.Errorf("remove: index %d out of range [0:%d]", i, len(n.Children))
+	}
+	c := n.Children[i]
+	n.Children = append(n.Children[:i:i], n.Children[i+1:]...)
+	c.parent = nil
+	return c, nil
+}
+
+// Detach removes n from the children of its parent.
+func (n *Node) Detach() {
+	if i := n.Index(); i >= 0 {
+		n.parent.Remove(i)
+	}
+	n.parent = nil
+}
@@ -0,0 +1,147 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestNode(t *testing.T) {
+	t.Run("parse", testParseNode)
+	t.Run("encode", testEncodeNode)
+	t.Run("mutate", testMutateNode)
+	t.Run("errors", testNodeErrors)
+}
+
+var nodeBytes = []byte{
+	0x30, 0x80,
+	0x02, 0x01, 0x05,
+	0xa1, 0x05, 0x0c, 0x03, 0x66, 0x6f, 0x6f,
+	0x30, 0x03, 0x01, 0x01, 0xff,
+	0x00, 0x00,
+}
+
+func testParseNode(t *testing.T) {
+	root, err := Parse(nodeBytes)
+	if err != nil {
+		t.Fatalf("parse: fail to parse! %s", err)
+	}
+	if root.Ident != Sequence || !root.Indefinite || root.Len() != 3 {
+		t.Fatalf("parse: root mismatched! %s (indefinite: %t, children: %d)", root.Ident, root.Indefinite, root.Len())
+	}
+	data := []struct {
+		Node    *Node
+		Ident   Ident
+		Offset  int
+		Content []byte
+	}{
+		{Node: root.Child(0), Ident: Int, Offset: 2, Content: []byte{0x05}},
+		{Node: root.Child(1), Ident: NewConstructed(1).Context(), Offset: 5},
+		{Node: root.Child(1).Child(0), Ident: UTF8String, Offset: 7, Content: []byte("foo")},
+		{Node: root.Child(2).Child(0), Ident: Bool, Offset: 14, Content: []byte{0xff}},
+	}
+	for _, d := range data {
+		if d.Node == nil {
+			t.Errorf("%s: node not found", d.Ident)
+			continue
+		}
+		if d.Node.Ident != d.Ident {
+			t.Errorf("%s: ident mismatched! got %s", d.Ident, d.Node.Ident)
+		}
+		if d.Node.Offset != d.Offset {
+			t.Errorf("%s: offset mismatched! want %d, got %d", d.Ident, d.Offset, d.Node.Offset)
+		}
+		if !bytes.Equal(d.Node.Content, d.Content) {
+			t.Errorf("%s: content mismatched! want %x, got %x", d.Ident, d.Content, d.Node.Content)
+		}
+	}
+	if n := root.Find(Sequence); n == nil || n.Index() != 2 || n.Parent() != root {
+		t.Errorf("parse: sequence not found")
+	}
+	var count int
+	root.Walk(func(n *Node) bool {
+		count++
+		return n.Ident != Sequence || n == root
+	})
+	if count != 5 {
+		t.Errorf("parse: walk mismatched! want 5 nodes, got %d", count)
+	}
+}
+
+func testEncodeNode(t *testing.T) {
+	root, err := Parse(nodeBytes)
+	if err != nil {
+		t.Fatalf("encode: fail to parse! %s", err)
+	}
+	want := []byte{
+		0x30, 0x0f,
+		0x02, 0x01, 0x05,
+		0xa1, 0x05, 0x0c, 0x03, 0x66, 0x6f, 0x6f,
+		0x30, 0x03, 0x01, 0x01, 0xff,
+	}
+	got, err := root.Encode()
+	if err != nil {
+		t.Fatalf("encode: fail to encode! %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encode: bytes mismatched! want %x, got %x", want, got)
+	}
+	var x int
+	if err := root.Child(0).Decode(&x); err != nil || x != 5 {
+		t.Errorf("encode: fail to decode node (%d)! %v", x, err)
+	}
+}
+
+func testMutateNode(t *testing.T) {
+	root, err := Parse(nodeBytes)
+	if err != nil {
+		t.Fatalf("mutate: fail to parse! %s", err)
+	}
+	root.Child(1).Child(0).SetContent([]byte("foobar"))
+	if _, err := root.Remove(2); err != nil {
+		t.Fatalf("mutate: fail to remove node! %s", err)
+	}
+	if err := root.Insert(0, NewPrimitiveNode(Null, nil)); err != nil {
+		t.Fatalf("mutate: fail to insert node! %s", err)
+	}
+	if err := root.Replace(1, NewPrimitiveNode(Int, []byte{0x01, 0x00})); err != nil {
+		t.Fatalf("mutate: fail to replace node! %s", err)
+	}
+	root.Append(NewConstructedNode(Set, root.Child(0)))
+
+	want := []byte{
+		0x30, 0x12,
+		0x02, 0x02, 0x01, 0x00,
+		0xa1, 0x08, 0x0c, 0x06, 0x66, 0x6f, 0x6f, 0x62, 0x61, 0x72,
+		0x31, 0x02, 0x05, 0x00,
+	}
+	got, err := root.Encode()
+	if err != nil {
+		t.Fatalf("mutate: fail to encode! %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("mutate: bytes mismatched! want %x, got %x", want, got)
+	}
+	if err := root.Insert(4, NewPrimitiveNode(Null, nil)); err == nil {
+		t.Errorf("mutate: node inserted out of range")
+	}
+}
+
+func testNodeErrors(t *testing.T) {
+	var se *SyntaxError
+	_, err := Parse([]byte{0x30, 0x06, 0x02, 0x01, 0x05, 0x0c, 0x03, 0x66})
+	if !errors.As(err, &se) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("errors: expected truncated *SyntaxError, got %T (%v)", err, err)
+	} else if se.Path != "[1]" || se.Offset != 5 {
+		t.Errorf("errors: error mismatched! got path %s at %d", se.Path, se.Offset)
+	}
+	if _, err := Parse([]byte{0x05, 0x00, 0x05, 0x00}); err == nil {
+		t.Errorf("errors: trailing bytes accepted")
+	}
+	root := NewPrimitiveNode(Int, nil)
+	root.Children = []*Node{NewPrimitiveNode(Int, nil)}
+	if _, err := root.Encode(); err == nil {
+		t.Errorf("errors: primitive node with children encoded")
+	}
+}
@@ -0,0 +1,1386 @@
+package ber
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// constraint is a PER-visible constraint (X.691 10) on the value or on the size
+// of a field. It is given by the range and size options of the field tag:
+//
+//	range:0..255  range:-1..MAX  range:MIN..0  size:1..16  size:4
+type constraint struct {
+	lb, ub       int64
+	hasLB, hasUB bool
+}
+
+func parseConstraint(str string) (constraint, error) {
+	var (
+		c   constraint
+		err error
+	)
+	lo, hi, ok := strings.Cut(strings.TrimSpace(str), "..")
+	if !ok {
+		hi = lo
+	}
+	if lo = strings.TrimSpace(lo); lo != "MIN" {
+		if c.lb, err = strconv.ParseInt(lo, 0, 64); err != nil {
+			return c, fmt.Errorf("%s: invalid lower bound", str)
+		}
+		c.hasLB = true
+	}
+	if hi = strings.TrimSpace(hi); hi != "MAX" {
+		if c.ub, err = strconv.ParseInt(hi, 0, 64); err != nil {
+			return c, fmt.Errorf("%s: invalid upper bound", str)
+		}
+		c.hasUB = true
+	}
+	if c.hasLB && c.hasUB && c.lb > c.ub {
+		return c, fmt.Errorf("%s: lower bound greater than upper bound", str)
+	}
+	return c, nil
+}
+
+// fixed reports whether c allows a single value.
+func (c constraint) fixed() bool {
+	return c.hasLB && c.hasUB && c.lb == c.ub
+}
+
+// small reports whether c has an upper bound lower than 64K. Lengths with such
+// a constraint are encoded as constrained whole numbers.
+func (c constraint) small() bool {
+	return c.hasUB && c.ub < 65536
+}
+
+func (c constraint) check(n int64) error {
+	if (c.hasLB && n < c.lb) || (c.hasUB && n > c.ub) {
+		return fmt.Errorf("%d: value out of range %s", n, c)
+	}
+	return nil
+}
+
+func (c constraint) String() string {
+	lo, hi := "MIN", "MAX"
+	if c.hasLB {
+		lo = strconv.FormatInt(c.lb, 10)
+	}
+	if c.hasUB {
+		hi = strconv.FormatInt(c.ub, 10)
+	}
+	return lo + ".." + hi
+}
+
+// alphabet describes the characters of a known-multiplier character string
+// type and the number of bits used to encode each of them.
+type alphabet struct {
+	unaligned int
+	aligned   int
+	valid     func(rune) bool
+}
+
+var (
+	ia5Alphabet = alphabet{
+		unaligned: 7,
+		aligned:   8,
+		valid: func(r rune) bool {
+			return r < utf8.RuneSelf
+		},
+	}
+	printableAlphabet = alphabet{
+		unaligned: 7,
+		aligned:   8,
+		valid: func(r rune) bool {
+			return r < utf8.RuneSelf && ValidPrintableString(string(r))
+		},
+	}
+	visibleAlphabet = alphabet{
+		unaligned: 7,
+		aligned:   8,
+		valid: func(r rune) bool {
+			return r >= ' ' && r <= '~'
+		},
+	}
+)
+
+// perInfo gives what the PER encoding of a value depends on besides its Go
+// type.
+type perInfo struct {
+	// universal type selected by the field tag
+	id    Ident
+	opts  fieldOptions
+	names []string
+	named bool
+}
+
+// item gives the information used by the items of a list: the value
+// constraint and the universal type apply to them.
+func (i perInfo) item() perInfo {
+	return perInfo{
+		id: i.id,
+		opts: fieldOptions{
+			value: i.opts.value,
+		},
+	}
+}
+
+func fieldInfo(sf reflect.StructField, ft fieldTag) perInfo {
+	info := perInfo{
+		id:   ft.id,
+		opts: ft.opts,
+	}
+	info.names, info.named = namedBitsForField(sf)
+	return info
+}
+
+// MarshalUPER gives the encoding of v with the unaligned variant of the Packed
+// Encoding Rules (X.691). See MarshalAPER.
+func MarshalUPER(v interface{}) ([]byte, error) {
+	return marshalPER(v, false)
+}
+
+// MarshalAPER gives the encoding of v with the aligned variant of the Packed
+// Encoding Rules (X.691).
+//
+// PER encodings depend on the constraints of the ASN.1 types. They are given by
+// the options of the ber tags of the fields:
+//
+//	range:lb..ub  bounds of an integer, of the integers of a list
+//	size:lb..ub   bounds of the length of a string, of a bit string or of a list
+//
+// where lb can be MIN and ub can be MAX. A single number gives a fixed size.
+// Values without constraint use the encodings of unconstrained types. The
+// fields of a struct are encoded in order after a bitmap giving the presence of
+// its optional fields (optional, default and omitempty). A CHOICE is encoded
+// with the index of its alternative in the canonical order of their tags.
+// Extensible types, open types (Raw, Tagged, empty interfaces) and the types
+// implementing Marshaler can not be encoded.
+//
+// Strings are encoded as UTF8String except when tagged with ia5, printable or
+// octetstr. Size constraints are checked for every string but they are only
+// PER-visible for the known-multiplier character strings and OCTET STRING.
+func MarshalAPER(v interface{}) ([]byte, error) {
+	return marshalPER(v, true)
+}
+
+func marshalPER(v interface{}, aligned bool) ([]byte, error) {
+	var (
+		p   = perEncoder{aligned: aligned}
+		val = reflect.ValueOf(v)
+	)
+	if !val.IsValid() {
+		return nil, fmt.Errorf("per: nil value can not be encoded")
+	}
+	if err := p.encode(val, perInfo{}); err != nil {
+		return nil, rootPath(valueError(err), val.Type())
+	}
+	// the complete encoding of a value without bits is a single octet (X.691
+	// 11.1.3)
+	if p.bits == 0 {
+		return []byte{0}, nil
+	}
+	return p.buf, nil
+}
+
+// UnmarshalUPER decodes the unaligned PER encoding of a value written by
+// MarshalUPER in the value pointed to by v. The Go type of v should have the
+// constraints used to encode the value.
+func UnmarshalUPER(data []byte, v interface{}) error {
+	return unmarshalPER(data, v, false)
+}
+
+// UnmarshalAPER decodes the aligned PER encoding of a value written by
+// MarshalAPER in the value pointed to by v. The Go type of v should have the
+// constraints used to encode the value.
+func UnmarshalAPER(data []byte, v interface{}) error {
+	return unmarshalPER(data, v, true)
+}
+
+func unmarshalPER(data []byte, v interface{}, aligned bool) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("per: non nil pointer expected (got %T)", v)
+	}
+	p := perDecoder{
+		aligned: aligned,
+		buf:     data,
+	}
+	if err := p.decode(val.Elem(), perInfo{}); err != nil {
+		return rootPath(p.wrapError(err), val.Type())
+	}
+	size := (p.pos + 7) / 8
+	if size == 0 {
+		size = 1
+	}
+	if size < len(data) {
+		return rootPath(p.wrapError(fmt.Errorf("%d bytes after value", len(data)-size)), val.Type())
+	}
+	return nil
+}
+
+type perEncoder struct {
+	aligned bool
+	buf     []byte
+	// number of bits written in buf
+	bits int
+}
+
+func (p *perEncoder) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if p.bits%8 == 0 {
+			p.buf = append(p.buf, 0)
+		}
+		if (v>>uint(i))&1 == 1 {
+			p.buf[len(p.buf)-1] |= 0x80 >> uint(p.bits%8)
+		}
+		p.bits++
+	}
+}
+
+func (p *perEncoder) writeBytes(b []byte) {
+	if p.bits%8 == 0 {
+		p.buf = append(p.buf, b...)
+		p.bits += 8 * len(b)
+		return
+	}
+	for _, c := range b {
+		p.writeBits(uint64(c), 8)
+	}
+}
+
+// align pads the output to the next octet boundary in the aligned variant.
+func (p *perEncoder) align() {
+	if p.aligned && p.bits%8 != 0 {
+		p.bits += 8 - p.bits%8
+	}
+}
+
+// encodeConstrained writes the constrained whole number v in the range lb..ub
+// (X.691 11.5).
+func (p *perEncoder) encodeConstrained(v, lb, ub int64) {
+	var (
+		// number of values of the range, 0 if it has 2^64 values
+		size = uint64(ub) - uint64(lb) + 1
+		x    = uint64(v) - uint64(lb)
+	)
+	switch {
+	case size == 1:
+	case !p.aligned || (size != 0 && size <= 255):
+		p.writeBits(x, bits.Len64(size-1))
+	case size == 256:
+		p.align()
+		p.writeBits(x, 8)
+	case size != 0 && size <= 65536:
+		p.align()
+		p.writeBits(x, 16)
+	default:
+		n := octetsFor(x)
+		p.writeBits(uint64(n-1), bits.Len64(uint64(octetsFor(size-1)-1)))
+		p.align()
+		p.writeBits(x, 8*n)
+	}
+}
+
+// encodeLength writes the length determinant of n units constrained by c
+// followed by the units written by fn (X.691 11.9). fn is called for each
+// fragment of a length greater than 16K.
+func (p *perEncoder) encodeLength(n int, c constraint, fn func(i, j int) error) error {
+	if c.small() {
+		if !c.fixed() {
+			p.encodeConstrained(int64(n), c.lb, c.ub)
+		}
+		return fn(0, n)
+	}
+	for i := 0; ; {
+		p.align()
+		switch r := n - i; {
+		case r < 128:
+			p.writeBits(uint64(r), 8)
+			return fn(i, n)
+		case r < 16384:
+			p.writeBits(0x8000|uint64(r), 16)
+			return fn(i, n)
+		default:
+			m := r / 16384
+			if m > 4 {
+				m = 4
+			}
+			p.writeBits(0xc0|uint64(m), 8)
+			if err := fn(i, i+m*16384); err != nil {
+				return err
+			}
+			i += m * 16384
+		}
+	}
+}
+
+func (p *perEncoder) encode(val reflect.Value, info perInfo) error {
+	if !info.named {
+		info.names, info.named = registeredNamedBits(val.Type())
+	}
+	if info.named {
+		bs, err := namedBitString(val, info.names)
+		if err != nil {
+			return err
+		}
+		// trailing zero bits are removed then added back up to the lower
+		// bound of the size constraint (X.691 16.3)
+		for bs.BitLength > 0 && bs.At(bs.BitLength-1) == 0 {
+			bs.BitLength--
+		}
+		size := info.opts.size
+		if size.hasLB && int64(bs.BitLength) < size.lb {
+			bs.BitLength = int(size.lb)
+		}
+		if n := (bs.BitLength + 7) / 8; n > len(bs.Bytes) {
+			bs.Bytes = append(bs.Bytes, make([]byte, n-len(bs.Bytes))...)
+		} else {
+			bs.Bytes = bs.Bytes[:n]
+		}
+		return p.encodeBits(bs, size)
+	}
+	switch typ := val.Type(); {
+	case typ == rawtype || typ == taggedtype:
+		return fmt.Errorf("%s can not be encoded with PER", typ)
+	case typ == timetype:
+		buf, err := berContents(val, timeIdent(info.id))
+		if err != nil {
+			return err
+		}
+		return p.encodeChars(string(buf), visibleAlphabet, constraint{})
+	case typ == bitstringtype:
+		return p.encodeBits(val.Interface().(Bits), info.opts.size)
+	case typ == bigtype:
+		x := val.Interface().(big.Int)
+		if info.opts.value.hasUB && info.opts.value.hasLB {
+			if !x.IsInt64() {
+				return fmt.Errorf("%s: value out of range %s", &x, info.opts.value)
+			}
+			return p.encodeInt(x.Int64(), info.opts.value)
+		}
+		return p.encodeBig(&x, info.opts.value)
+	case typ == oidtype:
+		id := ObjectId
+		if info.id == RelObjectId {
+			id = RelObjectId
+		}
+		buf, err := berContents(val, id)
+		if err != nil {
+			return err
+		}
+		return p.encodeOctets(buf, constraint{})
+	case val.Kind() != reflect.Ptr && val.Kind() != reflect.Interface && val.CanInterface() && typ.Implements(marshaltype):
+		return fmt.Errorf("%s can not be encoded with PER", typ)
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		var x uint64
+		if val.Bool() {
+			x = 1
+		}
+		p.writeBits(x, 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return p.encodeInt(val.Int(), info.opts.value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if x := val.Uint(); x > math.MaxInt64 {
+			return p.encodeBig(new(big.Int).SetUint64(x), info.opts.value)
+		}
+		return p.encodeInt(int64(val.Uint()), info.opts.value)
+	case reflect.Float32, reflect.Float64:
+		buf, err := berContents(val, Real)
+		if err != nil {
+			return err
+		}
+		return p.encodeOctets(buf, constraint{})
+	case reflect.String:
+		return p.encodeString(val.String(), info)
+	case reflect.Slice, reflect.Array:
+		if val.Type() == bytestype {
+			return p.encodeOctets(val.Bytes(), info.opts.size)
+		}
+		return p.encodeList(val, info)
+	case reflect.Map:
+		return p.encodeMap(val, info)
+	case reflect.Struct:
+		return p.encodeStruct(val)
+	case reflect.Ptr:
+		if val.IsNil() {
+			return fmt.Errorf("%s: nil value can not be encoded", val.Type())
+		}
+		return p.encode(val.Elem(), info)
+	case reflect.Interface:
+		c, ok := registeredChoice(val.Type())
+		if !ok {
+			return fmt.Errorf("%s can not be encoded with PER", val.Type())
+		}
+		return p.encodeChoice(val, c)
+	default:
+		return fmt.Errorf("%s can not be encoded with PER", val.Type())
+	}
+	return nil
+}
+
+func (p *perEncoder) encodeInt(v int64, c constraint) error {
+	if err := c.check(v); err != nil {
+		return err
+	}
+	if c.hasLB && c.hasUB {
+		p.encodeConstrained(v, c.lb, c.ub)
+		return nil
+	}
+	return p.encodeBig(big.NewInt(v), c)
+}
+
+// encodeBig writes the semi-constrained or the unconstrained whole number x
+// (X.691 11.7 and 11.8).
+func (p *perEncoder) encodeBig(x *big.Int, c constraint) error {
+	if (c.hasLB && x.Cmp(big.NewInt(c.lb)) < 0) || (c.hasUB && x.Cmp(big.NewInt(c.ub)) > 0) {
+		return fmt.Errorf("%s: value out of range %s", x, c)
+	}
+	var buf []byte
+	if c.hasLB {
+		buf = new(big.Int).Sub(x, big.NewInt(c.lb)).Bytes()
+		if len(buf) == 0 {
+			buf = []byte{0}
+		}
+	} else {
+		buf = encodeBigInt(x)
+	}
+	return p.encodeOctets(buf, constraint{})
+}
+
+// encodeOctets writes the octets of an OCTET STRING with the size constraint c
+// (X.691 17).
+func (p *perEncoder) encodeOctets(b []byte, c constraint) error {
+	if err := c.check(int64(len(b))); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	switch {
+	case c.fixed() && c.ub <= 2:
+		p.writeBytes(b)
+		return nil
+	case c.fixed() && c.ub < 65536:
+		p.align()
+		p.writeBytes(b)
+		return nil
+	default:
+		return p.encodeLength(len(b), c, func(i, j int) error {
+			p.align()
+			p.writeBytes(b[i:j])
+			return nil
+		})
+	}
+}
+
+// encodeBits writes the bits of a BIT STRING with the size constraint c (X.691
+// 16).
+func (p *perEncoder) encodeBits(bs Bits, c constraint) error {
+	if err := c.check(int64(bs.BitLength)); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	write := func(i, j int) error {
+		for ; i < j; i++ {
+			p.writeBits(uint64(bs.At(i)), 1)
+		}
+		return nil
+	}
+	switch {
+	case c.fixed() && c.ub <= 16:
+		return write(0, bs.BitLength)
+	case c.fixed() && c.ub < 65536:
+		p.align()
+		return write(0, bs.BitLength)
+	default:
+		return p.encodeLength(bs.BitLength, c, func(i, j int) error {
+			p.align()
+			return write(i, j)
+		})
+	}
+}
+
+func (p *perEncoder) encodeString(str string, info perInfo) error {
+	switch info.id {
+	case IA5String:
+		return p.encodeChars(str, ia5Alphabet, info.opts.size)
+	case PrintableString:
+		return p.encodeChars(str, printableAlphabet, info.opts.size)
+	case OctetString:
+		return p.encodeOctets([]byte(str), info.opts.size)
+	default:
+		if err := info.opts.size.check(int64(utf8.RuneCountInString(str))); err != nil {
+			return fmt.Errorf("size: %w", err)
+		}
+		return p.encodeOctets([]byte(str), constraint{})
+	}
+}
+
+// encodeChars writes the characters of a known-multiplier character string
+// with the size constraint c (X.691 30.5).
+func (p *perEncoder) encodeChars(str string, a alphabet, c constraint) error {
+	for _, r := range str {
+		if !a.valid(r) {
+			return fmt.Errorf("%q: invalid character in %q", r, str)
+		}
+	}
+	if err := c.check(int64(len(str))); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	size := a.unaligned
+	if p.aligned {
+		size = a.aligned
+	}
+	var (
+		short = c.hasUB && c.ub*int64(size) <= 16
+		write = func(i, j int) error {
+			if !short {
+				p.align()
+			}
+			for ; i < j; i++ {
+				p.writeBits(uint64(str[i]), size)
+			}
+			return nil
+		}
+	)
+	if c.fixed() && c.ub < 65536 {
+		return write(0, len(str))
+	}
+	return p.encodeLength(len(str), c, write)
+}
+
+// encodeList writes the items of a SEQUENCE OF (X.691 20).
+func (p *perEncoder) encodeList(val reflect.Value, info perInfo) error {
+	size := info.opts.size
+	if val.Kind() == reflect.Array && !size.hasUB {
+		size = constraint{
+			lb:    int64(val.Len()),
+			ub:    int64(val.Len()),
+			hasLB: true,
+			hasUB: true,
+		}
+	}
+	if err := size.check(int64(val.Len())); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	return p.encodeLength(val.Len(), size, func(i, j int) error {
+		for ; i < j; i++ {
+			if err := p.encode(val.Index(i), info.item()); err != nil {
+				return prefixPath(valueError(err), indexPath(i))
+			}
+		}
+		return nil
+	})
+}
+
+// encodeMap writes the entries of a map as a SEQUENCE OF key and value pairs
+// ordered by the encoding of their key.
+func (p *perEncoder) encodeMap(val reflect.Value, info perInfo) error {
+	var (
+		keys = val.MapKeys()
+		octs = make([][]byte, len(keys))
+	)
+	for i, k := range keys {
+		px := perEncoder{aligned: p.aligned}
+		if err := px.encode(k, perInfo{}); err != nil {
+			return prefixPath(valueError(err), keyPath(k))
+		}
+		octs[i] = px.buf
+	}
+	sort.Sort(keysByEncoding{keys: keys, octs: octs})
+	if err := info.opts.size.check(int64(len(keys))); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	return p.encodeLength(len(keys), info.opts.size, func(i, j int) error {
+		for _, k := range keys[i:j] {
+			if err := p.encode(k, perInfo{}); err != nil {
+				return prefixPath(valueError(err), keyPath(k))
+			}
+			if err := p.encode(val.MapIndex(k), perInfo{}); err != nil {
+				return prefixPath(valueError(err), keyPath(k))
+			}
+		}
+		return nil
+	})
+}
+
+// keysByEncoding sorts the keys of a map by the octets of their encoding.
+type keysByEncoding struct {
+	keys []reflect.Value
+	octs [][]byte
+}
+
+func (k keysByEncoding) Len() int {
+	return len(k.keys)
+}
+
+func (k keysByEncoding) Less(i, j int) bool {
+	return bytes.Compare(k.octs[i], k.octs[j]) < 0
+}
+
+func (k keysByEncoding) Swap(i, j int) {
+	k.keys[i], k.keys[j] = k.keys[j], k.keys[i]
+	k.octs[i], k.octs[j] = k.octs[j], k.octs[i]
+}
+
+// encodeStruct writes the presence bitmap of the optional fields of a SEQUENCE
+// followed by its fields (X.691 19).
+func (p *perEncoder) encodeStruct(val reflect.Value) error {
+	var (
+		typ  = val.Type()
+		tags = structTags(typ, Implicit)
+		list = make([]bool, val.NumField())
+	)
+	for i := range list {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident {
+			continue
+		}
+		if ft.err != nil {
+			return prefixPath(valueError(ft.err), sf.Name)
+		}
+		list[i] = true
+		if !ft.opts.canBeAbsent() && !ft.omit {
+			continue
+		}
+		absent, err := absentField(f, ft)
+		if err != nil {
+			return prefixPath(valueError(err), sf.Name)
+		}
+		list[i] = !absent
+		if absent {
+			p.writeBits(0, 1)
+		} else {
+			p.writeBits(1, 1)
+		}
+	}
+	for i, ok := range list {
+		if !ok {
+			continue
+		}
+		sf := typ.Field(i)
+		if err := p.encode(val.Field(i), fieldInfo(sf, tags[i])); err != nil {
+			return prefixPath(valueError(err), sf.Name)
+		}
+	}
+	return nil
+}
+
+// absentField reports whether the optional field f is not encoded: it is nil,
+// it is zero and tagged with omitempty or it has its default value.
+func absentField(f reflect.Value, ft fieldTag) (bool, error) {
+	switch {
+	case ft.opts.optional && isNil(f):
+		return true, nil
+	case ft.omit && f.IsZero():
+		return true, nil
+	case ft.opts.hasDef:
+		def, err := defaultValue(f.Type(), ft.opts.def)
+		if err != nil {
+			return false, err
+		}
+		return reflect.DeepEqual(f.Interface(), def.Interface()), nil
+	default:
+		return false, nil
+	}
+}
+
+// encodeChoice writes the index of the alternative held by val followed by its
+// value (X.691 23).
+func (p *perEncoder) encodeChoice(val reflect.Value, c *choice) error {
+	if val.IsNil() {
+		return fmt.Errorf("choice: %s: no alternative selected", val.Type())
+	}
+	var (
+		alt  = val.Elem()
+		list = c.alternatives()
+		x    = -1
+	)
+	for i := range list {
+		if list[i] == alt.Type() {
+			x = i
+			break
+		}
+	}
+	if x < 0 {
+		return fmt.Errorf("choice: %s is not an alternative of %s", alt.Type(), val.Type())
+	}
+	p.encodeConstrained(int64(x), 0, int64(len(list)-1))
+	return p.encode(alt, perInfo{})
+}
+
+// alternatives gives the types of the alternatives of c in the canonical order
+// of their tags (X.680 8.6).
+func (c *choice) alternatives() []reflect.Type {
+	list := make([]reflect.Type, 0, len(c.types))
+	for typ := range c.types {
+		list = append(list, typ)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		x, y := c.types[list[i]], c.types[list[j]]
+		if x.Class() != y.Class() {
+			return x.Class() < y.Class()
+		}
+		return x.Tag() < y.Tag()
+	})
+	return list
+}
+
+// berContents gives the contents of the DER encoding of val with the
+// identifier id. PER reuses them for REAL, OBJECT IDENTIFIER and the time types.
+func berContents(val reflect.Value, id Ident) ([]byte, error) {
+	e := Encoder{rules: DER}
+	if err := e.encodeValue(val, id); err != nil {
+		return nil, err
+	}
+	n, err := Parse(e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return n.Content, nil
+}
+
+func timeIdent(id Ident) Ident {
+	if id == UniversalTime {
+		return UniversalTime
+	}
+	return GeneralizedTime
+}
+
+func octetsFor(x uint64) int {
+	if x == 0 {
+		return 1
+	}
+	return (bits.Len64(x) + 7) / 8
+}
+
+type perDecoder struct {
+	aligned bool
+	buf     []byte
+	// number of bits read from buf
+	pos int
+	// number of values being decoded around the current one
+	depth int
+}
+
+// enter is called before decoding a value. It fails when the values are nested
+// too deeply.
+func (p *perDecoder) enter() error {
+	if p.depth >= maxDepth {
+		return fmt.Errorf("%w (more than %d levels)", ErrTooDeep, maxDepth)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *perDecoder) exit() {
+	p.depth--
+}
+
+// wrapError gives err the type of the error describing a failure when decoding
+// the bits at the current position.
+func (p *perDecoder) wrapError(err error) error {
+	if err == nil || isTyped(err) {
+		return err
+	}
+	if isStructural(err) {
+		return &StructuralError{
+			Offset: p.pos / 8,
+			Err:    err,
+		}
+	}
+	return &SyntaxError{
+		Offset: p.pos / 8,
+		Err:    err,
+	}
+}
+
+func (p *perDecoder) truncated(n int) error {
+	var (
+		offset = p.pos / 8
+		have   = len(p.buf) - offset
+	)
+	if have < 0 {
+		have = 0
+	}
+	return &TruncatedError{
+		Offset: offset,
+		Want:   (p.pos%8 + n + 7) / 8,
+		Have:   have,
+	}
+}
+
+func (p *perDecoder) readBits(n int) (uint64, error) {
+	if p.pos+n > 8*len(p.buf) {
+		return 0, p.truncated(n)
+	}
+	var x uint64
+	for i := 0; i < n; i++ {
+		x = x<<1 | uint64(p.buf[p.pos/8]>>(7-uint(p.pos%8))&1)
+		p.pos++
+	}
+	return x, nil
+}
+
+func (p *perDecoder) readBytes(n int) ([]byte, error) {
+	if n < 0 || p.pos+8*n > 8*len(p.buf) {
+		return nil, p.truncated(8 * n)
+	}
+	if p.pos%8 == 0 {
+		b := append([]byte{}, p.buf[p.pos/8:p.pos/8+n]...)
+		p.pos += 8 * n
+		return b, nil
+	}
+	b := make([]byte, n)
+	for i := range b {
+		x, _ := p.readBits(8)
+		b[i] = byte(x)
+	}
+	return b, nil
+}
+
+func (p *perDecoder) align() {
+	if p.aligned && p.pos%8 != 0 {
+		p.pos += 8 - p.pos%8
+	}
+}
+
+func (p *perDecoder) decodeConstrained(lb, ub int64) (int64, error) {
+	var (
+		size = uint64(ub) - uint64(lb) + 1
+		x    uint64
+		err  error
+	)
+	switch {
+	case size == 1:
+	case !p.aligned || (size != 0 && size <= 255):
+		x, err = p.readBits(bits.Len64(size - 1))
+	case size == 256:
+		p.align()
+		x, err = p.readBits(8)
+	case size != 0 && size <= 65536:
+		p.align()
+		x, err = p.readBits(16)
+	default:
+		var n uint64
+		if n, err = p.readBits(bits.Len64(uint64(octetsFor(size-1) - 1))); err != nil {
+			break
+		}
+		p.align()
+		x, err = p.readBits(8 * int(n+1))
+	}
+	if err != nil {
+		return 0, err
+	}
+	if size != 0 && x >= size {
+		return 0, fmt.Errorf("%d: value out of range %d..%d", x, lb, ub)
+	}
+	return int64(uint64(lb) + x), nil
+}
+
+// decodeLength reads the length determinant constrained by c and calls fn
+// with the number of units of each fragment.
+func (p *perDecoder) decodeLength(c constraint, fn func(n int) error) error {
+	if c.small() {
+		n := c.lb
+		if !c.fixed() {
+			var err error
+			if n, err = p.decodeConstrained(c.lb, c.ub); err != nil {
+				return err
+			}
+		}
+		return fn(int(n))
+	}
+	for {
+		p.align()
+		x, err := p.readBits(8)
+		if err != nil {
+			return err
+		}
+		switch {
+		case x&0x80 == 0:
+			return fn(int(x))
+		case x&0x40 == 0:
+			y, err := p.readBits(8)
+			if err != nil {
+				return err
+			}
+			return fn(int(x&0x3f)<<8 | int(y))
+		default:
+			m := int(x & 0x3f)
+			if m < 1 || m > 4 {
+				return fmt.Errorf("invalid fragment of %d blocks", m)
+			}
+			if err := fn(m * 16384); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *perDecoder) decode(val reflect.Value, info perInfo) error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.exit()
+	if !info.named {
+		info.names, info.named = registeredNamedBits(val.Type())
+	}
+	if info.named {
+		bs, err := p.decodeBits(info.opts.size)
+		if err != nil {
+			return err
+		}
+		return setNamedBits(val, info.names, bs)
+	}
+	switch typ := val.Type(); {
+	case typ == rawtype || typ == taggedtype:
+		return fmt.Errorf("%s can not be decoded with PER", typ)
+	case typ == timetype:
+		str, err := p.decodeChars(visibleAlphabet, constraint{})
+		if err != nil {
+			return err
+		}
+		return NewPrimitiveNode(timeIdent(info.id), []byte(str)).Decode(val.Addr().Interface())
+	case typ == bitstringtype:
+		bs, err := p.decodeBits(info.opts.size)
+		if err == nil {
+			val.Set(reflect.ValueOf(bs))
+		}
+		return err
+	case typ == bigtype:
+		x := val.Addr().Interface().(*big.Int)
+		if info.opts.value.hasUB && info.opts.value.hasLB {
+			i, err := p.decodeInt(info.opts.value)
+			if err == nil {
+				x.SetInt64(i)
+			}
+			return err
+		}
+		b, err := p.decodeBig(info.opts.value)
+		if err == nil {
+			x.Set(b)
+		}
+		return err
+	case typ == oidtype:
+		id := ObjectId
+		if info.id == RelObjectId {
+			id = RelObjectId
+		}
+		buf, err := p.decodeOctets(constraint{})
+		if err != nil {
+			return err
+		}
+		return NewPrimitiveNode(id, buf).Decode(val.Addr().Interface())
+	case val.Kind() != reflect.Ptr && val.Kind() != reflect.Interface && reflect.PtrTo(typ).Implements(unmarshaltype):
+		return fmt.Errorf("%s can not be decoded with PER", typ)
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		x, err := p.readBits(1)
+		if err != nil {
+			return err
+		}
+		val.SetBool(x == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := p.decodeInt(info.opts.value)
+		if err != nil {
+			return err
+		}
+		if val.OverflowInt(i) {
+			return fmt.Errorf("%d: %w", i, ErrOverflow)
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var (
+			b   *big.Int
+			err error
+		)
+		if c := info.opts.value; c.hasLB && c.hasUB {
+			var i int64
+			i, err = p.decodeInt(c)
+			b = big.NewInt(i)
+		} else {
+			b, err = p.decodeBig(c)
+		}
+		if err != nil {
+			return err
+		}
+		if b.Sign() < 0 || !b.IsUint64() || val.OverflowUint(b.Uint64()) {
+			return fmt.Errorf("%s: %w", b, ErrOverflow)
+		}
+		val.SetUint(b.Uint64())
+	case reflect.Float32, reflect.Float64:
+		buf, err := p.decodeOctets(constraint{})
+		if err != nil {
+			return err
+		}
+		return NewPrimitiveNode(Real, buf).Decode(val.Addr().Interface())
+	case reflect.String:
+		str, err := p.decodeString(info)
+		if err == nil {
+			val.SetString(str)
+		}
+		return err
+	case reflect.Slice:
+		if val.Type() == bytestype {
+			buf, err := p.decodeOctets(info.opts.size)
+			if err == nil {
+				val.SetBytes(buf)
+			}
+			return err
+		}
+		return p.decodeSlice(val, info)
+	case reflect.Array:
+		return p.decodeArray(val, info)
+	case reflect.Map:
+		return p.decodeMap(val, info)
+	case reflect.Struct:
+		return p.decodeStruct(val)
+	case reflect.Ptr:
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		return p.decode(val.Elem(), info)
+	case reflect.Interface:
+		c, ok := registeredChoice(val.Type())
+		if !ok {
+			return fmt.Errorf("%s can not be decoded with PER", val.Type())
+		}
+		return p.decodeChoice(val, c)
+	default:
+		return fmt.Errorf("%s can not be decoded with PER", val.Type())
+	}
+	return nil
+}
+
+func (p *perDecoder) decodeInt(c constraint) (int64, error) {
+	if c.hasLB && c.hasUB {
+		return p.decodeConstrained(c.lb, c.ub)
+	}
+	b, err := p.decodeBig(c)
+	if err != nil {
+		return 0, err
+	}
+	if !b.IsInt64() {
+		return 0, fmt.Errorf("%s: %w", b, ErrOverflow)
+	}
+	return b.Int64(), nil
+}
+
+func (p *perDecoder) decodeBig(c constraint) (*big.Int, error) {
+	buf, err := p.decodeOctets(constraint{})
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("integer without octets")
+	}
+	var x *big.Int
+	if c.hasLB {
+		x = new(big.Int).SetBytes(buf)
+		x.Add(x, big.NewInt(c.lb))
+	} else {
+		x = decodeBigInt(buf)
+	}
+	if c.hasUB && x.Cmp(big.NewInt(c.ub)) > 0 {
+		return nil, fmt.Errorf("%s: value out of range %s", x, c)
+	}
+	return x, nil
+}
+
+func (p *perDecoder) decodeOctets(c constraint) ([]byte, error) {
+	switch {
+	case c.fixed() && c.ub <= 2:
+		return p.readBytes(int(c.ub))
+	case c.fixed() && c.ub < 65536:
+		p.align()
+		return p.readBytes(int(c.ub))
+	}
+	var buf []byte
+	err := p.decodeLength(c, func(n int) error {
+		p.align()
+		b, err := p.readBytes(n)
+		buf = append(buf, b...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.check(int64(len(buf))); err != nil {
+		return nil, fmt.Errorf("size: %w", err)
+	}
+	return buf, nil
+}
+
+func (p *perDecoder) decodeBits(c constraint) (Bits, error) {
+	var (
+		bs   Bits
+		read = func(n int) error {
+			if p.pos+n > 8*len(p.buf) {
+				return p.truncated(n)
+			}
+			for i := 0; i < n; i++ {
+				x, _ := p.readBits(1)
+				bs.Set(bs.BitLength, x == 1)
+			}
+			return nil
+		}
+		err error
+	)
+	switch {
+	case c.fixed() && c.ub <= 16:
+		err = read(int(c.ub))
+	case c.fixed() && c.ub < 65536:
+		p.align()
+		err = read(int(c.ub))
+	default:
+		err = p.decodeLength(c, func(n int) error {
+			p.align()
+			return read(n)
+		})
+	}
+	if err != nil {
+		return bs, err
+	}
+	if err := c.check(int64(bs.BitLength)); err != nil {
+		return bs, fmt.Errorf("size: %w", err)
+	}
+	return bs, nil
+}
+
+func (p *perDecoder) decodeString(info perInfo) (string, error) {
+	switch info.id {
+	case IA5String:
+		return p.decodeChars(ia5Alphabet, info.opts.size)
+	case PrintableString:
+		return p.decodeChars(printableAlphabet, info.opts.size)
+	case OctetString:
+		buf, err := p.decodeOctets(info.opts.size)
+		return string(buf), err
+	default:
+		buf, err := p.decodeOctets(constraint{})
+		if err != nil {
+			return "", err
+		}
+		if !utf8.Valid(buf) {
+			return "", fmt.Errorf("invalid UTF-8 string")
+		}
+		if err := info.opts.size.check(int64(utf8.RuneCount(buf))); err != nil {
+			return "", fmt.Errorf("size: %w", err)
+		}
+		return string(buf), nil
+	}
+}
+
+func (p *perDecoder) decodeChars(a alphabet, c constraint) (string, error) {
+	size := a.unaligned
+	if p.aligned {
+		size = a.aligned
+	}
+	var (
+		str   strings.Builder
+		short = c.hasUB && c.ub*int64(size) <= 16
+		read  = func(n int) error {
+			if !short {
+				p.align()
+			}
+			if p.pos+n*size > 8*len(p.buf) {
+				return p.truncated(n * size)
+			}
+			for i := 0; i < n; i++ {
+				x, _ := p.readBits(size)
+				if r := rune(x); !a.valid(r) {
+					return fmt.Errorf("%q: invalid character", r)
+				}
+				str.WriteByte(byte(x))
+			}
+			return nil
+		}
+		err error
+	)
+	if c.fixed() && c.ub < 65536 {
+		err = read(int(c.ub))
+	} else {
+		err = p.decodeLength(c, read)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := c.check(int64(str.Len())); err != nil {
+		return "", fmt.Errorf("size: %w", err)
+	}
+	return str.String(), nil
+}
+
+func (p *perDecoder) decodeSlice(val reflect.Value, info perInfo) error {
+	var (
+		typ  = val.Type()
+		list = reflect.MakeSlice(typ, 0, 0)
+	)
+	err := p.decodeLength(info.opts.size, func(n int) error {
+		// the items are only allocated once it is known that the input can
+		// hold them. An item takes at least one bit: lists of values without
+		// contents can not be longer than the bits left
+		if left := 8*len(p.buf) - p.pos; n > left {
+			return fmt.Errorf("%d items announced with %d bits left", n, left)
+		}
+		offset := list.Len()
+		list = reflect.AppendSlice(list, reflect.MakeSlice(typ, n, n))
+		for i := offset; i < list.Len(); i++ {
+			if err := p.decode(list.Index(i), info.item()); err != nil {
+				return prefixPath(p.wrapError(err), indexPath(i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := info.opts.size.check(int64(list.Len())); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	val.Set(list)
+	return nil
+}
+
+func (p *perDecoder) decodeArray(val reflect.Value, info perInfo) error {
+	size := info.opts.size
+	if !size.hasUB {
+		size = constraint{
+			lb:    int64(val.Len()),
+			ub:    int64(val.Len()),
+			hasLB: true,
+			hasUB: true,
+		}
+	}
+	var i int
+	err := p.decodeLength(size, func(n int) error {
+		if i+n > val.Len() {
+			return fmt.Errorf("%d elements can not be decoded into %s", i+n, val.Type())
+		}
+		for j := 0; j < n; i, j = i+1, j+1 {
+			if err := p.decode(val.Index(i), info.item()); err != nil {
+				return prefixPath(p.wrapError(err), indexPath(i))
+			}
+		}
+		return nil
+	})
+	if err == nil && i != val.Len() {
+		err = fmt.Errorf("%d elements can not be decoded into %s", i, val.Type())
+	}
+	return err
+}
+
+func (p *perDecoder) decodeMap(val reflect.Value, info perInfo) error {
+	var (
+		typ   = val.Type()
+		count int
+	)
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(typ))
+	}
+	err := p.decodeLength(info.opts.size, func(n int) error {
+		for i := 0; i < n; i++ {
+			var (
+				key = reflect.New(typ.Key()).Elem()
+				v   = reflect.New(typ.Elem()).Elem()
+			)
+			if err := p.decode(key, perInfo{}); err != nil {
+				return prefixPath(p.wrapError(err), indexPath(count))
+			}
+			if err := p.decode(v, perInfo{}); err != nil {
+				return prefixPath(p.wrapError(err), keyPath(key))
+			}
+			val.SetMapIndex(key, v)
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := info.opts.size.check(int64(count)); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	return nil
+}
+
+func (p *perDecoder) decodeStruct(val reflect.Value) error {
+	var (
+		typ  = val.Type()
+		tags = structTags(typ, Implicit)
+		list = make([]bool, val.NumField())
+	)
+	for i := range list {
+		var (
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident {
+			continue
+		}
+		if ft.err != nil {
+			return prefixPath(valueError(ft.err), sf.Name)
+		}
+		list[i] = true
+		if !ft.opts.canBeAbsent() && !ft.omit {
+			continue
+		}
+		x, err := p.readBits(1)
+		if err != nil {
+			return err
+		}
+		list[i] = x == 1
+	}
+	for i, ok := range list {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident {
+			continue
+		}
+		if !ok {
+			if ft.opts.hasDef {
+				def, err := defaultValue(f.Type(), ft.opts.def)
+				if err != nil {
+					return prefixPath(valueError(err), sf.Name)
+				}
+				f.Set(def)
+			}
+			continue
+		}
+		if err := p.decode(f, fieldInfo(sf, ft)); err != nil {
+			return prefixPath(p.wrapError(err), sf.Name)
+		}
+	}
+	return nil
+}
+
+func (p *perDecoder) decodeChoice(val reflect.Value, c *choice) error {
+	list := c.alternatives()
+	if len(list) == 0 {
+		return fmt.Errorf("choice: %s has no alternative", val.Type())
+	}
+	x, err := p.decodeConstrained(0, int64(len(list)-1))
+	if err != nil {
+		return err
+	}
+	alt := reflect.New(list[x]).Elem()
+	if err := p.decode(alt, perInfo{}); err != nil {
+		return err
+	}
+	val.Set(alt)
+	return nil
+}
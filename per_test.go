@@ -0,0 +1,316 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPER(t *testing.T) {
+	t.Run("encode", testEncodePER)
+	t.Run("decode", testDecodePER)
+	t.Run("fragments", testPERFragments)
+	t.Run("constraints", testPERConstraints)
+	t.Run("canonical", testPERCanonical)
+	t.Run("errors", testPERErrors)
+}
+
+type perSequence struct {
+	A bool
+	B int    `ber:"optional,range:0..7"`
+	C string `ber:"ia5,size:1..4"`
+}
+
+type perOptional struct {
+	A *int `ber:"optional,range:0..7"`
+	B int  `ber:"default:3,range:0..7"`
+}
+
+type perList struct {
+	Items []int `ber:"size:0..3,range:0..255"`
+}
+
+type perBits struct {
+	Flags Bits     `ber:"size:4"`
+	Usage keyUsage `ber:"size:9"`
+}
+
+type perSample struct {
+	Version int64  `ber:"range:0..3"`
+	Serial  uint64 `ber:"range:0..MAX"`
+	Value   int
+	Ratio   float64
+	Name    string `ber:"size:1..32"`
+	Code    string `ber:"printable,size:2"`
+	Data    []byte `ber:"octetstr,size:0..8"`
+	Algo    OID
+	Shape   choiceShape
+	Tags    []string          `ber:"ia5,size:1..4"`
+	Extra   *perSequence      `ber:"optional"`
+	Attrs   map[string]uint16 `ber:"size:0..4"`
+	Count   int               `ber:"default:1,range:0..10"`
+}
+
+var perSampleValue = perSample{
+	Version: 2,
+	Serial:  math.MaxUint64,
+	Value:   -129,
+	Ratio:   0.5,
+	Name:    "héllo",
+	Code:    "FR",
+	Data:    []byte{0xde, 0xad, 0xbe, 0xef},
+	Algo:    OID("1.2.840.113549"),
+	Shape:   choiceLabel("label"),
+	Tags:    []string{"a", "bc"},
+	Extra:   &perSequence{A: true, C: "x"},
+	Attrs:   map[string]uint16{"x": 1, "y": 65535},
+	Count:   4,
+}
+
+func testPERCanonical(t *testing.T) {
+	var (
+		when  = time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 3600))
+		local = struct{ When time.Time }{When: when}
+		utc   = struct{ When time.Time }{When: when.UTC()}
+	)
+	got, err := MarshalAPER(local)
+	if err != nil {
+		t.Fatalf("canonical: fail to encode time! %s", err)
+	}
+	want, err := MarshalAPER(utc)
+	if err != nil {
+		t.Fatalf("canonical: fail to encode time! %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("canonical: time bytes mismatched! want %x, got %x", want, got)
+	}
+	if !bytes.Contains(got, []byte("20210304040607Z")) {
+		t.Errorf("canonical: time should be encoded in UTC (got %q)", got)
+	}
+
+	// 9 is encoded before 10 while fmt.Sprint gives "10" before "9"
+	var (
+		attrs = map[int]bool{10: true, 9: false}
+		octs  = []byte{0x02, 0x01, 0x09, 0x00, 0x01, 0x0a, 0x80}
+	)
+	if got, err = MarshalAPER(attrs); err != nil {
+		t.Fatalf("canonical: fail to encode map! %s", err)
+	}
+	if !bytes.Equal(got, octs) {
+		t.Errorf("canonical: map bytes mismatched! want %x, got %x", octs, got)
+	}
+}
+
+func testEncodePER(t *testing.T) {
+	data := []struct {
+		Name    string
+		Value   interface{}
+		Aligned []byte
+		Packed  []byte
+	}{
+		{
+			Name:    "bool",
+			Value:   true,
+			Aligned: []byte{0x80},
+			Packed:  []byte{0x80},
+		},
+		{
+			Name:    "int",
+			Value:   128,
+			Aligned: []byte{0x02, 0x00, 0x80},
+			Packed:  []byte{0x02, 0x00, 0x80},
+		},
+		{
+			Name:    "empty",
+			Value:   struct{}{},
+			Aligned: []byte{0x00},
+			Packed:  []byte{0x00},
+		},
+		{
+			Name:    "sequence",
+			Value:   perSequence{A: true, B: 3, C: "ab"},
+			Aligned: []byte{0xda, 0x61, 0x62},
+			Packed:  []byte{0xdb, 0x87, 0x10},
+		},
+		{
+			Name:    "default",
+			Value:   perOptional{B: 3},
+			Aligned: []byte{0x00},
+			Packed:  []byte{0x00},
+		},
+		{
+			Name:    "list",
+			Value:   perList{Items: []int{1, 2}},
+			Aligned: []byte{0x80, 0x01, 0x02},
+			Packed:  []byte{0x80, 0x40, 0x80},
+		},
+		{
+			Name:    "bits",
+			Value:   perBits{Flags: Bits{Bytes: []byte{0xa0}, BitLength: 4}, Usage: digitalSignature | keyCertSign},
+			Aligned: []byte{0xa8, 0x40},
+			Packed:  []byte{0xa8, 0x40},
+		},
+		{
+			Name: "named",
+			Value: struct {
+				Usage keyUsage `ber:"size:0..8"`
+			}{Usage: digitalSignature},
+			Aligned: []byte{0x10, 0x80},
+			Packed:  []byte{0x18},
+		},
+		{
+			Name:    "choice",
+			Value:   struct{ Shape choiceShape }{Shape: choiceSquare{Side: 2}},
+			Aligned: []byte{0x40, 0x01, 0x02},
+			Packed:  []byte{0x40, 0x40, 0x80},
+		},
+	}
+	for _, d := range data {
+		got, err := MarshalAPER(d.Value)
+		if err != nil {
+			t.Errorf("%s: fail to encode value (aligned)! %s", d.Name, err)
+			continue
+		}
+		if !bytes.Equal(got, d.Aligned) {
+			t.Errorf("%s: aligned bytes mismatched! want %x, got %x", d.Name, d.Aligned, got)
+		}
+		got, err = MarshalUPER(d.Value)
+		if err != nil {
+			t.Errorf("%s: fail to encode value (unaligned)! %s", d.Name, err)
+			continue
+		}
+		if !bytes.Equal(got, d.Packed) {
+			t.Errorf("%s: unaligned bytes mismatched! want %x, got %x", d.Name, d.Packed, got)
+		}
+	}
+}
+
+func testDecodePER(t *testing.T) {
+	for _, aligned := range []bool{true, false} {
+		var (
+			buf []byte
+			err error
+			got perSample
+		)
+		if aligned {
+			buf, err = MarshalAPER(perSampleValue)
+		} else {
+			buf, err = MarshalUPER(perSampleValue)
+		}
+		if err != nil {
+			t.Fatalf("decode: fail to encode value (aligned: %t)! %s", aligned, err)
+		}
+		if aligned {
+			err = UnmarshalAPER(buf, &got)
+		} else {
+			err = UnmarshalUPER(buf, &got)
+		}
+		if err != nil {
+			t.Fatalf("decode: fail to decode value (aligned: %t)! %s", aligned, err)
+		}
+		if !reflect.DeepEqual(got, perSampleValue) {
+			t.Errorf("decode: value mismatched (aligned: %t)! want %+v, got %+v", aligned, perSampleValue, got)
+		}
+	}
+
+	var opt perOptional
+	if err := UnmarshalUPER([]byte{0x00}, &opt); err != nil {
+		t.Fatalf("decode: fail to decode optional fields! %s", err)
+	}
+	if want := (perOptional{B: 3}); !reflect.DeepEqual(opt, want) {
+		t.Errorf("decode: optional fields mismatched! want %+v, got %+v", want, opt)
+	}
+}
+
+func testPERFragments(t *testing.T) {
+	in := make([]byte, 16384+5)
+	for i := range in {
+		in[i] = byte(i)
+	}
+	buf, err := MarshalAPER(in)
+	if err != nil {
+		t.Fatalf("fragments: fail to encode value! %s", err)
+	}
+	if len(buf) != len(in)+2 || buf[0] != 0xc1 || buf[16385] != 0x05 {
+		t.Fatalf("fragments: unexpected length determinants (%d bytes, %x, %x)", len(buf), buf[0], buf[16385])
+	}
+	var got []byte
+	if err := UnmarshalAPER(buf, &got); err != nil {
+		t.Fatalf("fragments: fail to decode value! %s", err)
+	}
+	if !bytes.Equal(got, in) {
+		t.Errorf("fragments: bytes mismatched")
+	}
+
+	in = in[:16384]
+	if buf, err = MarshalUPER(in); err != nil {
+		t.Fatalf("fragments: fail to encode value! %s", err)
+	}
+	if len(buf) != len(in)+2 || buf[len(buf)-1] != 0 {
+		t.Fatalf("fragments: final empty fragment missing")
+	}
+}
+
+func testPERConstraints(t *testing.T) {
+	data := []struct {
+		Name  string
+		Value interface{}
+	}{
+		{Name: "range", Value: perSequence{B: 8, C: "a"}},
+		{Name: "size", Value: perSequence{C: "abcde"}},
+		{Name: "alphabet", Value: perSequence{C: "é"}},
+		{Name: "list", Value: perList{Items: []int{1, 2, 3, 4}}},
+		{Name: "items", Value: perList{Items: []int{256}}},
+		{Name: "tag", Value: struct {
+			A int `ber:"range:5..1"`
+		}{}},
+		{Name: "raw", Value: Raw{0x05, 0x00}},
+		{Name: "named", Value: struct {
+			Usage keyUsage `ber:"size:0..2"`
+		}{Usage: keyCertSign}},
+	}
+	for _, d := range data {
+		if _, err := MarshalUPER(d.Value); err == nil {
+			t.Errorf("%s: expected error, got nil", d.Name)
+		}
+	}
+}
+
+func testPERErrors(t *testing.T) {
+	var s perSequence
+	err := UnmarshalAPER([]byte{0xda, 0x61}, &s)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated: unexpected error! %v", err)
+	}
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Path != "perSequence.C" {
+		t.Errorf("truncated: unexpected path! %v", err)
+	}
+	if err := UnmarshalAPER([]byte{0xda, 0x61, 0x62, 0x00}, &s); err == nil {
+		t.Errorf("trailing: expected error, got nil")
+	}
+	var i int8
+	if err := UnmarshalUPER([]byte{0x02, 0x01, 0x00}, &i); !errors.Is(err, ErrOverflow) {
+		t.Errorf("overflow: unexpected error! %v", err)
+	}
+	// with all the bits set, every node has a next node
+	var zeros struct {
+		List []int `ber:"range:0..0"`
+	}
+	if err := UnmarshalAPER(bytes.Repeat([]byte{0xc4}, 200), &zeros); err == nil {
+		t.Errorf("fragments: expected error, got nil")
+	}
+	var n perNode
+	if err := UnmarshalUPER(bytes.Repeat([]byte{0xff}, 1<<20), &n); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("depth: unexpected error! %v", err)
+	}
+}
+
+type perNode struct {
+	V    bool
+	Next *perNode `ber:"optional"`
+}
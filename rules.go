@@ -0,0 +1,371 @@
+package ber
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cerSegmentSize is the maximum number of octets of a string encoded with the
+// primitive form in CER. Longer strings are split in segments of this size.
+const cerSegmentSize = 1000
+
+type element struct {
+	id  Ident
+	raw []byte
+}
+
+// sortElements reorders the elements already written in the buffer of the
+// Encoder. If unique is set, two elements with the same tag are reported as an
+// error since they can not appear in a SET.
+func (e *Encoder) sortElements(cmp func(element, element) int, unique bool) error {
+	list, err := splitElements(e.buf)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return cmp(list[i], list[j]) < 0
+	})
+	buf := make([]byte, 0, len(e.buf))
+	for i := range list {
+		if unique && i > 0 && compareTag(list[i-1], list[i]) == 0 {
+			return fmt.Errorf("set: duplicate tag %d (class %d)", list[i].id.Tag(), list[i].id.Class())
+		}
+		buf = append(buf, list[i].raw...)
+	}
+	e.buf = buf
+	return nil
+}
+
+func splitElements(buf []byte) ([]element, error) {
+	var list []element
+	for len(buf) > 0 {
+		id, _, err := decodeIdentifier(buf)
+		if err != nil {
+			return nil, err
+		}
+		n, err := measure(buf)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, element{id: id, raw: buf[:n]})
+		buf = buf[n:]
+	}
+	return list, nil
+}
+
+// encodeIndefinite wraps the elements written in the buffer of the Encoder in a
+// constructed value using the indefinite form.
+func (e *Encoder) encodeIndefinite(i Ident) ([]byte, error) {
+	id, err := encodeIdentifier(i.Class(), i.Type(), i.Tag())
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(id)+len(e.buf)+3)
+	buf = append(buf, id...)
+	buf = append(buf, 0x80)
+	buf = append(buf, e.buf...)
+	return append(buf, 0x00, 0x00), nil
+}
+
+// encodeSegments encodes b as a constructed string made of OCTET STRING
+// segments of at most cerSegmentSize octets (X.690 9.2).
+func (e *Encoder) encodeSegments(b []byte, tag Ident) error {
+	ex := e.child()
+	for len(b) > 0 {
+		n := cerSegmentSize
+		if len(b) < n {
+			n = len(b)
+		}
+		if err := ex.encodeBytes(b[:n], OctetString); err != nil {
+			e.err = err
+			return e.err
+		}
+		b = b[n:]
+	}
+	return e.merge(&ex, tag.Constructed())
+}
+
+// compareTag orders elements by their tags: universal class first, then
+// application, context specific and private, and within a class, by tag
+// number (X.690 8.6 and 10.3).
+func compareTag(a, b element) int {
+	if ac, bc := a.id.Class(), b.id.Class(); ac != bc {
+		if ac < bc {
+			return -1
+		}
+		return 1
+	}
+	if at, bt := a.id.Tag(), b.id.Tag(); at != bt {
+		if at < bt {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// compareEncoding orders elements as the components of a SET OF are ordered
+// in DER: by the octets of their encodings (X.690 11.6).
+func compareEncoding(a, b element) int {
+	return bytes.Compare(a.raw, b.raw)
+}
+
+func compareSet(a, b element) int {
+	if c := compareTag(a, b); c != 0 {
+		return c
+	}
+	return compareEncoding(a, b)
+}
+
+// encodeCanonicalDecimalFloat encodes f in the NR3 form required by DER
+// (X.690 11.3.2): the mantissa has no leading nor trailing zeros, is followed
+// by a full stop and the exponent is written with a sign only when it is zero.
+func encodeCanonicalDecimalFloat(f float64) ([]byte, error) {
+	str := strconv.FormatFloat(math.Abs(f), 'e', -1, 64)
+	x := strings.IndexByte(str, 'e')
+	if x < 0 {
+		return nil, fmt.Errorf("%f: invalid decimal representation", f)
+	}
+	exp, err := strconv.Atoi(str[x+1:])
+	if err != nil {
+		return nil, err
+	}
+	digits := strings.Replace(str[:x], ".", "", 1)
+	exp -= len(digits) - 1
+	for len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+		exp++
+	}
+	b := make([]byte, 0, 32)
+	b = append(b, 0x03)
+	if f < 0 {
+		b = append(b, '-')
+	}
+	b = append(b, digits...)
+	b = append(b, '.', 'E')
+	if exp == 0 {
+		b = append(b, '+', '0')
+	} else {
+		b = strconv.AppendInt(b, int64(exp), 10)
+	}
+	return b, nil
+}
+
+// RulesError describes an encoding that is valid BER but that is not allowed
+// by the rules selected on a Decoder or an Encoder. Offset is -1 for errors
+// reported by an Encoder.
+type RulesError struct {
+	Rules  Rules
+	Offset int
+	Reason string
+}
+
+func (e *RulesError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("%s: %s", e.Rules, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s (offset %d)", e.Rules, e.Reason, e.Offset)
+}
+
+func (d *Decoder) rulesError(offset int, reason string) error {
+	return &RulesError{
+		Rules:  d.rules,
+		Offset: offset,
+		Reason: reason,
+	}
+}
+
+func (e *Encoder) rulesError(reason string) error {
+	return &RulesError{
+		Rules:  e.rules,
+		Offset: -1,
+		Reason: reason,
+	}
+}
+
+func (d *Decoder) checkIdentifier(b []byte) error {
+	if d.rules == BER || len(b) <= 1 {
+		return nil
+	}
+	if b[1] == 0x80 {
+		return d.rulesError(d.offset, "tag number with leading zero")
+	}
+	if tag, _ := decode128(b[1:]); tag < 0x1F {
+		return d.rulesError(d.offset, "tag number should use the short form")
+	}
+	return nil
+}
+
+func (d *Decoder) checkLength(b []byte, size int) error {
+	if d.rules == BER {
+		return nil
+	}
+	if d.rules == CER && d.ident.Type() == Constructed {
+		if size != indefinite {
+			return d.rulesError(d.offset, "constructed value should use the indefinite length")
+		}
+		return nil
+	}
+	if b[0]>>7 == 0 {
+		return nil
+	}
+	switch {
+	case size == indefinite:
+		return d.rulesError(d.offset, "indefinite length")
+	case size <= 127:
+		return d.rulesError(d.offset, "length should use the short form")
+	case b[1] == 0:
+		return d.rulesError(d.offset, "length with leading zero")
+	}
+	return nil
+}
+
+func (d *Decoder) checkPrimitive(id Ident) error {
+	if d.rules != DER || id.Type() == Primitive {
+		return nil
+	}
+	return d.rulesError(d.offset, "constructed encoding of string")
+}
+
+func (d *Decoder) checkSegment(size int) error {
+	if d.rules != CER || size <= cerSegmentSize {
+		return nil
+	}
+	return d.rulesError(d.offset, "string should be split in segments")
+}
+
+func (d *Decoder) checkBool(b byte) error {
+	if d.rules == BER || b == 0x00 || b == 0xFF {
+		return nil
+	}
+	return d.rulesError(d.offset, fmt.Sprintf("invalid boolean value %02x", b))
+}
+
+func (d *Decoder) checkInt(b []byte) error {
+	if d.rules == BER {
+		return nil
+	}
+	if !validInt(b) {
+		return d.rulesError(d.offset, "integer not encoded in the minimal number of octets")
+	}
+	return nil
+}
+
+func (d *Decoder) checkFloat(b []byte) error {
+	if d.rules == BER {
+		return nil
+	}
+	var (
+		info = b[0]
+		str  = b[1:]
+	)
+	switch {
+	case info>>7 == 1:
+		if info&0x3C != 0 {
+			return d.rulesError(d.offset, "real should use base 2 without scaling factor")
+		}
+		size := int(info&0x03) + 1
+		if size == 4 {
+			if len(str) == 0 {
+				return d.rulesError(d.offset, "real exponent missing")
+			}
+			size, str = int(str[0]), str[1:]
+		}
+		if len(str) <= size {
+			return d.rulesError(d.offset, "real mantissa missing")
+		}
+		if !validInt(str[:size]) {
+			return d.rulesError(d.offset, "real exponent not encoded in the minimal number of octets")
+		}
+		if str[len(str)-1]&0x01 == 0 {
+			return d.rulesError(d.offset, "real mantissa should be odd")
+		}
+	case info>>6 == 0:
+		if info&0x3F != 0x03 || !validNR3(str) {
+			return d.rulesError(d.offset, "real should use the canonical NR3 form")
+		}
+	default:
+		if len(str) > 0 {
+			return d.rulesError(d.offset, "invalid special real")
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) checkTime(b []byte) error {
+	if d.rules == BER {
+		return nil
+	}
+	if len(b) == 0 || b[len(b)-1] != 'Z' {
+		return d.rulesError(d.offset, "time should be expressed in UTC")
+	}
+	return nil
+}
+
+// checkSetOrder checks that the element at the current offset of the Decoder
+// is not lower than prev according to cmp. The returned element should be given
+// as prev to the next call.
+func (d *Decoder) checkSetOrder(prev element, cmp func(element, element) int) (element, error) {
+	if d.rules == BER {
+		return prev, nil
+	}
+	id, _, err := decodeIdentifier(d.buf[d.offset:])
+	if err != nil {
+		return prev, err
+	}
+	z, err := measure(d.buf[d.offset:])
+	if err != nil {
+		return prev, err
+	}
+	curr := element{id: id, raw: d.buf[d.offset : d.offset+z]}
+	if prev.raw != nil && cmp(prev, curr) > 0 {
+		return curr, d.rulesError(d.offset, "elements of set not sorted")
+	}
+	return curr, nil
+}
+
+func validInt(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	if len(b) == 1 {
+		return true
+	}
+	return !(b[0] == 0x00 && b[1]&0x80 == 0) && !(b[0] == 0xFF && b[1]&0x80 != 0)
+}
+
+// validNR3 checks that str is the canonical NR3 representation of a number as
+// produced by encodeCanonicalDecimalFloat.
+func validNR3(str []byte) bool {
+	x := bytes.Index(str, []byte(".E"))
+	if x < 0 {
+		return false
+	}
+	mant, exp := str[:x], str[x+2:]
+	if len(mant) > 0 && mant[0] == '-' {
+		mant = mant[1:]
+	}
+	if len(mant) == 0 || mant[0] == '0' || mant[len(mant)-1] == '0' || !isDigits(mant) {
+		return false
+	}
+	if string(exp) == "+0" {
+		return true
+	}
+	if len(exp) > 0 && exp[0] == '-' {
+		exp = exp[1:]
+	}
+	return len(exp) > 0 && exp[0] != '0' && isDigits(exp)
+}
+
+func isDigits(str []byte) bool {
+	for _, b := range str {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return true
+}
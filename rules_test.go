@@ -0,0 +1,414 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDER(t *testing.T) {
+	t.Run("bool", testDERBool)
+	t.Run("real", testDERReal)
+	t.Run("time", testDERTime)
+	t.Run("set-of", testDERSetOf)
+	t.Run("set", testDERSet)
+	t.Run("set-decode", testDERSetDecode)
+	t.Run("map", testDERMap)
+	t.Run("default", testDERDefault)
+	t.Run("constructed", testDERConstructed)
+	t.Run("strict", testDERStrict)
+}
+
+func testDERStrict(t *testing.T) {
+	type SetOf struct {
+		List []int `ber:"set"`
+	}
+	data := []struct {
+		Name  string
+		Input []byte
+		Value interface{}
+	}{
+		{Name: "bool", Input: []byte{0x01, 0x01, 0x01}, Value: new(bool)},
+		{Name: "int-padded", Input: []byte{0x02, 0x02, 0x00, 0x7f}, Value: new(int)},
+		{Name: "int-negative-padded", Input: []byte{0x02, 0x02, 0xff, 0x80}, Value: new(int)},
+		{Name: "int-empty", Input: []byte{0x02, 0x00}, Value: new(int)},
+		{Name: "length-long-form", Input: []byte{0x02, 0x81, 0x01, 0x01}, Value: new(int)},
+		{Name: "length-leading-zero", Input: append([]byte{0x04, 0x82, 0x00, 0x80}, make([]byte, 128)...), Value: new([]byte)},
+		{Name: "length-indefinite", Input: []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00}, Value: new([]int)},
+		{Name: "tag-long-form", Input: []byte{0x1f, 0x02, 0x01, 0x01}, Value: new(int)},
+		{Name: "string-constructed", Input: []byte{0x24, 0x05, 0x04, 0x03, 'f', 'o', 'o'}, Value: new(string)},
+		{Name: "real-base-10", Input: []byte{0x09, 0x04, 0x01, 0x31, 0x30, 0x30}, Value: new(float64)},
+		{Name: "real-even-mantissa", Input: []byte{0x09, 0x03, 0x80, 0x00, 0x02}, Value: new(float64)},
+		{Name: "time-offset", Input: append([]byte{0x18, 0x13}, "20191215190210+0000"...), Value: new(time.Time)},
+		{Name: "set-of-unsorted", Input: []byte{0x30, 0x08, 0x31, 0x06, 0x02, 0x01, 0x02, 0x02, 0x01, 0x01}, Value: new(SetOf)},
+	}
+	for _, d := range data {
+		dec := NewDecoder(d.Input)
+		dec.SetRules(DER)
+		err := dec.Decode(d.Value)
+		if err == nil {
+			t.Errorf("%s: decoding should have failed", d.Name)
+			continue
+		}
+		var re *RulesError
+		if !errors.As(err, &re) {
+			t.Errorf("%s: unexpected error type %T (%s)", d.Name, err, err)
+		}
+	}
+
+	valid := Encoder{rules: DER}
+	if err := valid.Encode(SetOf{List: []int{2, 1}}); err != nil {
+		t.Errorf("set-of: fail to encode! %s", err)
+		return
+	}
+	var got SetOf
+	dec := NewDecoder(valid.Bytes())
+	dec.SetRules(DER)
+	if err := dec.Decode(&got); err != nil {
+		t.Errorf("set-of: fail to decode canonical encoding! %s", err)
+	}
+}
+
+func testDERBool(t *testing.T) {
+	e := Encoder{rules: DER}
+	if err := e.EncodeBool(true); err != nil {
+		t.Errorf("bool: fail to encode! %s", err)
+		return
+	}
+	want := []byte{0x01, 0x01, 0xFF}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("bool: bytes mismatched! want %x, got %x", want, got)
+	}
+}
+
+func testDERReal(t *testing.T) {
+	data := []struct {
+		Input float64
+		Want  string
+	}{
+		{Input: 1, Want: "1.E+0"},
+		{Input: 100, Want: "1.E2"},
+		{Input: -100, Want: "-1.E2"},
+		{Input: 1.5, Want: "15.E-1"},
+		{Input: 0.15625, Want: "15625.E-5"},
+		{Input: 1234.5678, Want: "12345678.E-4"},
+	}
+	for _, d := range data {
+		e := Encoder{rules: DER}
+		if err := e.EncodeFloat10(d.Input); err != nil {
+			t.Errorf("%f: fail to encode real! %s", d.Input, err)
+			continue
+		}
+		want := append([]byte{0x09, byte(len(d.Want) + 1), 0x03}, d.Want...)
+		if got := e.Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("%f: bytes mismatched! want %x, got %x", d.Input, want, got)
+		}
+		got, err := NewDecoder(e.Bytes()).DecodeFloat()
+		if err != nil {
+			t.Errorf("%f: fail to decode real! %s", d.Input, err)
+			continue
+		}
+		if got != d.Input {
+			t.Errorf("real mismatched! want %f, got %f", d.Input, got)
+		}
+	}
+}
+
+func testDERTime(t *testing.T) {
+	var (
+		e    = Encoder{rules: DER}
+		when = time.Date(2019, 12, 15, 20, 2, 10, 0, time.FixedZone("CET", 3600))
+		want = append([]byte{0x18, 0x0f}, "20191215190210Z"...)
+	)
+	if err := e.EncodeGeneralizedTime(when); err != nil {
+		t.Errorf("time: fail to encode! %s", err)
+		return
+	}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("time: bytes mismatched! want %x, got %x", want, got)
+	}
+	got, err := NewDecoder(e.Bytes()).DecodeTime()
+	if err != nil {
+		t.Errorf("time: fail to decode! %s", err)
+		return
+	}
+	if !got.Equal(when) {
+		t.Errorf("time mismatched! want %s, got %s", when, got)
+	}
+}
+
+func testDERSetOf(t *testing.T) {
+	var (
+		data = struct {
+			List []int `ber:"set"`
+		}{
+			List: []int{256, 1, -1, 127},
+		}
+		want = []byte{
+			0x30, 0x0f,
+			0x31, 0x0d,
+			0x02, 0x01, 0x01,
+			0x02, 0x01, 0x7f,
+			0x02, 0x01, 0xff,
+			0x02, 0x02, 0x01, 0x00,
+		}
+		e = Encoder{rules: DER}
+	)
+	if err := e.Encode(data); err != nil {
+		t.Errorf("set-of: fail to encode! %s", err)
+		return
+	}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("set-of: bytes mismatched! want %x, got %x", want, got)
+	}
+}
+
+func testDERSet(t *testing.T) {
+	type Sample struct {
+		Name string `ber:"class:0x2,tag:2"`
+		Age  int    `ber:"class:0x2,tag:1"`
+		Ok   bool
+	}
+	var (
+		data = Sample{Name: "foo", Age: 42, Ok: true}
+		want = []byte{
+			0x31, 0x0b,
+			0x01, 0x01, 0xff,
+			0x81, 0x01, 0x2a,
+			0x82, 0x03, 'f', 'o', 'o',
+		}
+		e = Encoder{rules: DER}
+	)
+	if err := e.EncodeWithIdent(data, Set); err != nil {
+		t.Errorf("set: fail to encode! %s", err)
+		return
+	}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("set: bytes mismatched! want %x, got %x", want, got)
+	}
+
+	type Duplicate struct {
+		First  int `ber:"class:0x2,tag:1"`
+		Second int `ber:"class:0x2,tag:1"`
+	}
+	e = Encoder{rules: DER}
+	if err := e.EncodeWithIdent(Duplicate{}, Set); err == nil {
+		t.Errorf("set: duplicate tags should be rejected")
+	}
+}
+
+func testDERSetDecode(t *testing.T) {
+	type Inner struct {
+		B int `ber:"tag:2"`
+		A int `ber:"tag:1"`
+	}
+	type Sample struct {
+		Inner Inner `ber:"set"`
+		Name  string
+	}
+	var (
+		data = Sample{Inner: Inner{B: 2, A: 1}, Name: "foo"}
+		want = []byte{
+			0x30, 0x0d,
+			0x31, 0x06, 0x01, 0x01, 0x01, 0x02, 0x01, 0x02,
+			0x0c, 0x03, 'f', 'o', 'o',
+		}
+		e = Encoder{rules: DER}
+	)
+	if err := e.Encode(data); err != nil {
+		t.Fatalf("set-decode: fail to encode! %s", err)
+	}
+	got := e.Bytes()
+	if !bytes.Equal(got, want) {
+		t.Errorf("set-decode: bytes mismatched! want %x, got %x", want, got)
+	}
+	for _, r := range []Rules{BER, DER} {
+		var (
+			d   = NewDecoder(got)
+			res Sample
+		)
+		d.SetRules(r)
+		if err := d.Decode(&res); err != nil {
+			t.Errorf("set-decode: fail to decode with %s! %s", r, err)
+			continue
+		}
+		if res != data {
+			t.Errorf("set-decode: values mismatched with %s! want %+v, got %+v", r, data, res)
+		}
+	}
+}
+
+func testDERMap(t *testing.T) {
+	var (
+		data = map[string]int{
+			"foo": 128,
+			"bar": -128,
+		}
+		want = []byte{
+			0x31, 0x15,
+			0x30, 0x08, 0x0c, 0x03, 'b', 'a', 'r', 0x02, 0x01, 0x80,
+			0x30, 0x09, 0x0c, 0x03, 'f', 'o', 'o', 0x02, 0x02, 0x00, 0x80,
+		}
+		e = Encoder{rules: DER}
+	)
+	if err := e.Encode(data); err != nil {
+		t.Errorf("map: fail to encode! %s", err)
+		return
+	}
+	got := e.Bytes()
+	if !bytes.Equal(got, want) {
+		t.Errorf("map: bytes mismatched! want %x, got %x", want, got)
+	}
+	for _, r := range []Rules{BER, DER} {
+		var (
+			d   = NewDecoder(got)
+			res map[string]int
+		)
+		d.SetRules(r)
+		if err := d.Decode(&res); err != nil {
+			t.Errorf("map: fail to decode with %s! %s", r, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, data) {
+			t.Errorf("map: values mismatched with %s! want %v, got %v", r, data, res)
+		}
+	}
+}
+
+func testDERDefault(t *testing.T) {
+	type Sample struct {
+		Version int    `ber:"class:0x2,tag:0,default:1"`
+		Name    string `ber:"default:ber"`
+	}
+	data := []struct {
+		Rules Rules
+		Input Sample
+		Want  []byte
+	}{
+		{Rules: DER, Input: Sample{Version: 1, Name: "ber"}, Want: []byte{0x30, 0x00}},
+		{Rules: DER, Input: Sample{Version: 2, Name: "ber"}, Want: []byte{0x30, 0x03, 0x80, 0x01, 0x02}},
+		{Rules: DER, Input: Sample{Version: 1, Name: "der"}, Want: []byte{0x30, 0x05, 0x0c, 0x03, 'd', 'e', 'r'}},
+		{Rules: BER, Input: Sample{Version: 1, Name: "ber"}, Want: []byte{0x30, 0x08, 0x80, 0x01, 0x01, 0x0c, 0x03, 'b', 'e', 'r'}},
+	}
+	for _, d := range data {
+		e := Encoder{rules: d.Rules}
+		if err := e.Encode(d.Input); err != nil {
+			t.Errorf("default: fail to encode %+v! %s", d.Input, err)
+			continue
+		}
+		if got := e.Bytes(); !bytes.Equal(got, d.Want) {
+			t.Errorf("default: bytes mismatched! want %x, got %x", d.Want, got)
+		}
+	}
+}
+
+func testDERConstructed(t *testing.T) {
+	e := Encoder{rules: DER}
+	if err := e.EncodeStringWithIdent("foobar", UTF8String.Constructed()); err == nil {
+		t.Errorf("string: constructed encoding should be rejected")
+	}
+}
+
+func TestCER(t *testing.T) {
+	t.Run("constructed", testCERConstructed)
+	t.Run("segments", testCERSegments)
+	t.Run("strict", testCERStrict)
+}
+
+func testCERConstructed(t *testing.T) {
+	type Sample struct {
+		Int  int
+		List []int `ber:"set"`
+	}
+	var (
+		data = Sample{Int: 1, List: []int{2, 1}}
+		want = []byte{
+			0x30, 0x80,
+			0x02, 0x01, 0x01,
+			0x31, 0x80,
+			0x02, 0x01, 0x01,
+			0x02, 0x01, 0x02,
+			0x00, 0x00,
+			0x00, 0x00,
+		}
+		e = Encoder{rules: CER}
+	)
+	if err := e.Encode(data); err != nil {
+		t.Errorf("cer: fail to encode! %s", err)
+		return
+	}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("cer: bytes mismatched! want %x, got %x", want, got)
+		return
+	}
+	var (
+		got Sample
+		dec = NewDecoder(want)
+	)
+	dec.SetRules(CER)
+	if err := dec.Decode(&got); err != nil {
+		t.Errorf("cer: fail to decode! %s", err)
+		return
+	}
+	if got.Int != 1 || len(got.List) != 2 || got.List[0] != 1 || got.List[1] != 2 {
+		t.Errorf("cer: values mismatched! got %+v", got)
+	}
+}
+
+func testCERSegments(t *testing.T) {
+	str := bytes.Repeat([]byte("ber"), 800)
+
+	e := Encoder{rules: CER}
+	if err := e.EncodeBytes(str); err != nil {
+		t.Errorf("segments: fail to encode! %s", err)
+		return
+	}
+	buf := e.Bytes()
+	if buf[0] != 0x24 || buf[1] != 0x80 {
+		t.Errorf("segments: constructed indefinite form expected! got %x", buf[:2])
+		return
+	}
+	segment := []byte{0x04, 0x82, 0x03, 0xe8}
+	if !bytes.Equal(buf[2:6], segment) || !bytes.Equal(buf[1006:1010], segment) {
+		t.Errorf("segments: segments of 1000 bytes expected")
+		return
+	}
+	dec := NewDecoder(buf)
+	dec.SetRules(CER)
+	got, err := dec.DecodeBytes()
+	if err != nil {
+		t.Errorf("segments: fail to decode! %s", err)
+		return
+	}
+	if !bytes.Equal(got, str) {
+		t.Errorf("segments: bytes mismatched!")
+	}
+}
+
+func testCERStrict(t *testing.T) {
+	data := []struct {
+		Name  string
+		Input []byte
+		Value interface{}
+	}{
+		{Name: "definite-constructed", Input: []byte{0x30, 0x03, 0x02, 0x01, 0x01}, Value: new([]int)},
+		{Name: "long-primitive", Input: append([]byte{0x04, 0x82, 0x03, 0xe9}, make([]byte, 1001)...), Value: new([]byte)},
+		{Name: "short-constructed", Input: []byte{0x24, 0x80, 0x04, 0x01, 0x00, 0x00, 0x00}, Value: new([]byte)},
+		{Name: "bool", Input: []byte{0x01, 0x01, 0x01}, Value: new(bool)},
+	}
+	for _, d := range data {
+		dec := NewDecoder(d.Input)
+		dec.SetRules(CER)
+		err := dec.Decode(d.Value)
+		if err == nil {
+			t.Errorf("%s: decoding should have failed", d.Name)
+			continue
+		}
+		var re *RulesError
+		if !errors.As(err, &re) {
+			t.Errorf("%s: unexpected error type %T (%s)", d.Name, err, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package ber
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned by Select when no element matches a path.
+var ErrNotFound = errors.New("element not found")
+
+// Select gives the first element of buf matching path. The returned Raw shares
+// the memory of buf.
+//
+// A path is a list of selectors separated by slashes, each selector being
+// applied to the children of the elements selected by the previous one. The
+// first selector is applied to the elements of buf. A selector is one of:
+//
+//	n           the n-th element (from 0)
+//	[CLASS n]   the elements with the given class and tag number (eg:
+//	            [CONTEXT 3], [UNIVERSAL 2]). [n] is a shortcut for [CONTEXT n]
+//	*           all the elements
+//	**          the elements at any depth, including the current level
+//
+// Elements are only decoded as far as needed to evaluate the path.
+func Select(buf []byte, path string) (Raw, error) {
+	var found Raw
+	err := selectPath(buf, path, func(r Raw) bool {
+		found = r
+		return false
+	})
+	if err == nil && found == nil {
+		err = fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return found, err
+}
+
+// SelectAll gives all the elements of buf matching path in the order they
+// appear in buf. See Select for the syntax of path.
+func SelectAll(buf []byte, path string) ([]Raw, error) {
+	var list []Raw
+	err := selectPath(buf, path, func(r Raw) bool {
+		list = append(list, r)
+		return true
+	})
+	return list, err
+}
+
+func selectPath(buf []byte, path string, fn func(Raw) bool) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	d := Decoder{buf: buf}
+	_, err = d.selectElements(segs, 0, len(buf), func(s span) bool {
+		return fn(Raw(buf[s.offset:s.end:s.end]))
+	})
+	return err
+}
+
+// selector is one step of a path given to Select.
+type selector struct {
+	index     int
+	ident     Ident
+	tag       bool
+	recursive bool
+}
+
+func (s selector) match(id Ident, index int) bool {
+	switch {
+	case s.tag:
+		return id.Class() == s.ident.Class() && id.Tag() == s.ident.Tag()
+	case s.index >= 0:
+		return index == s.index
+	default:
+		return true
+	}
+}
+
+func parsePath(path string) ([]selector, error) {
+	var list []selector
+	for _, str := range strings.Split(strings.Trim(path, "/"), "/") {
+		s, err := parseSelector(strings.TrimSpace(str))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func parseSelector(str string) (selector, error) {
+	s := selector{index: -1}
+	switch {
+	case str == "*":
+	case str == "**":
+		s.recursive = true
+	case strings.HasPrefix(str, "[") && strings.HasSuffix(str, "]"):
+		var (
+			parts = strings.Fields(str[1 : len(str)-1])
+			class = Context
+		)
+		switch len(parts) {
+		case 1:
+		case 2:
+			x := indexName(classNames, strings.ToUpper(parts[0]))
+			if x < 0 {
+				return s, fmt.Errorf("%s: unknown class", parts[0])
+			}
+			class, parts = uint8(x), parts[1:]
+		default:
+			return s, fmt.Errorf("%s: invalid tag selector", str)
+		}
+		x, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return s, fmt.Errorf("%s: invalid tag number", str)
+		}
+		s.ident, s.tag = retag(0, class, uint32(x)), true
+	default:
+		x, err := strconv.ParseUint(str, 10, 31)
+		if err != nil {
+			return s, fmt.Errorf("%q: invalid selector", str)
+		}
+		s.index = int(x)
+	}
+	return s, nil
+}
+
+// span gives the bounds of an element in the buffer of a Decoder.
+type span struct {
+	id Ident
+	// offset of the element, of its contents and of the end of the element
+	offset  int
+	content int
+	end     int
+	// end of the contents of the element
+	limit int
+}
+
+// span decodes the identifier and the length of the element starting at offset.
+func (d *Decoder) span(offset int) (_ span, err error) {
+	d.offset = offset
+	defer d.wrapError(&err, offset)
+
+	var s span
+	id, n, err := d.decodeIdentifier()
+	if err != nil {
+		return s, err
+	}
+	d.offset += n
+	size, n, err := d.decodeLength()
+	if err != nil {
+		return s, err
+	}
+	d.offset += n
+	s.id, s.offset, s.content = id, offset, d.offset
+	if size != indefinite {
+		s.end = d.offset + size
+		s.limit = s.end
+		return s, nil
+	}
+	z, err := measure(d.buf[offset:])
+	if err != nil {
+		return s, err
+	}
+	s.end = offset + z
+	s.limit = s.end - 2
+	return s, nil
+}
+
+// selectElements calls fn for each element matching segs among the elements
+// found between offset and limit. It reports whether fn asked to continue.
+func (d *Decoder) selectElements(segs []selector, offset, limit int, fn func(span) bool) (bool, error) {
+	if err := d.enter(); err != nil {
+		d.wrapError(&err, offset)
+		return false, err
+	}
+	defer d.exit()
+	seg := segs[0]
+	for i := 0; offset < limit; i++ {
+		s, err := d.span(offset)
+		if err != nil {
+			return false, err
+		}
+		if s.end > limit {
+			err = fmt.Errorf("element longer than its parent")
+			d.wrapError(&err, offset)
+			return false, err
+		}
+		offset = s.end
+
+		var next bool
+		switch {
+		case seg.recursive && len(segs) == 1:
+			next = fn(s)
+		case seg.recursive && segs[1].match(s.id, i):
+			next, err = d.selectChildren(segs[2:], s, fn)
+		case seg.recursive:
+			next = true
+		case seg.match(s.id, i):
+			next, err = d.selectChildren(segs[1:], s, fn)
+		default:
+			continue
+		}
+		if err != nil || !next {
+			return next, err
+		}
+		if seg.recursive && s.id.Type() == Constructed {
+			next, err = d.selectElements(segs, s.content, s.limit, fn)
+			if err != nil || !next {
+				return next, err
+			}
+		}
+		if seg.index >= 0 {
+			break
+		}
+	}
+	return true, nil
+}
+
+// selectChildren calls fn with s if segs is empty or applies segs to the
+// children of s.
+func (d *Decoder) selectChildren(segs []selector, s span, fn func(span) bool) (bool, error) {
+	if len(segs) == 0 {
+		return fn(s), nil
+	}
+	if s.id.Type() != Constructed {
+		return true, nil
+	}
+	return d.selectElements(segs, s.content, s.limit, fn)
+}
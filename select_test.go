@@ -0,0 +1,87 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var selectBytes = []byte{
+	0x30, 0x80,
+	0x02, 0x01, 0x01,
+	0x0c, 0x01, 0x61,
+	0x30, 0x0d,
+	0x01, 0x01, 0xff,
+	0xa3, 0x08, 0x02, 0x01, 0x02, 0x30, 0x03, 0x02, 0x01, 0x03,
+	0x00, 0x00,
+	0x02, 0x01, 0x04,
+}
+
+func TestSelect(t *testing.T) {
+	data := []struct {
+		Path string
+		Want []Raw
+	}{
+		{Path: "0/0", Want: []Raw{{0x02, 0x01, 0x01}}},
+		{Path: "1", Want: []Raw{{0x02, 0x01, 0x04}}},
+		{Path: "0/2/[CONTEXT 3]/0", Want: []Raw{{0x02, 0x01, 0x02}}},
+		{Path: "/0/2/[3]/1/0/", Want: []Raw{{0x02, 0x01, 0x03}}},
+		{Path: "0/[UNIVERSAL 12]", Want: []Raw{{0x0c, 0x01, 0x61}}},
+		{Path: "0/*/[universal 1]", Want: []Raw{{0x01, 0x01, 0xff}}},
+		{
+			Path: "**/[UNIVERSAL 2]",
+			Want: []Raw{
+				{0x02, 0x01, 0x01},
+				{0x02, 0x01, 0x02},
+				{0x02, 0x01, 0x03},
+				{0x02, 0x01, 0x04},
+			},
+		},
+		{Path: "0/**/0", Want: []Raw{{0x02, 0x01, 0x01}, {0x01, 0x01, 0xff}, {0x02, 0x01, 0x02}, {0x02, 0x01, 0x03}}},
+		{Path: "0/2/0/*"},
+		{Path: "0/5"},
+	}
+	for _, d := range data {
+		got, err := SelectAll(selectBytes, d.Path)
+		if err != nil {
+			t.Errorf("%s: fail to select! %s", d.Path, err)
+			continue
+		}
+		if len(got) != len(d.Want) {
+			t.Errorf("%s: length mismatched! want %d, got %d (%x)", d.Path, len(d.Want), len(got), got)
+			continue
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], d.Want[i]) {
+				t.Errorf("%s: element mismatched! want %x, got %x", d.Path, d.Want[i], got[i])
+			}
+		}
+		raw, err := Select(selectBytes, d.Path)
+		if len(d.Want) == 0 {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: expected not found error, got %v", d.Path, err)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(raw, d.Want[0]) {
+			t.Errorf("%s: first element mismatched! want %x, got %x (%v)", d.Path, d.Want[0], raw, err)
+		}
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	for _, p := range []string{"", "0//1", "a", "[FOO 1]", "[CONTEXT]", "-1"} {
+		if _, err := Select(selectBytes, p); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: invalid path accepted (%v)", p, err)
+		}
+	}
+	// the first element is selected before the truncated one is reached
+	buf := []byte{0x30, 0x05, 0x05, 0x00, 0x02, 0x05, 0x01}
+	if _, err := Select(buf, "0/0"); err != nil {
+		t.Errorf("lazy: fail to select! %s", err)
+	}
+	var se *SyntaxError
+	if _, err := Select(buf, "0/1"); !errors.As(err, &se) {
+		t.Errorf("lazy: expected *SyntaxError, got %T (%v)", err, err)
+	}
+}
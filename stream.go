@@ -0,0 +1,115 @@
+package ber
+
+import (
+	"fmt"
+	"io"
+)
+
+// maximum number of bytes read at once when reading the contents of an
+// element. Reading by chunk avoids allocating the size announced by a length
+// before the bytes are effectively received.
+const streamChunk = 64 << 10
+
+// fill reads the next element from the reader of a stream Decoder once all the
+// buffered bytes have been consumed.
+func (d *Decoder) fill() error {
+	if d.r == nil || !d.Empty() {
+		return nil
+	}
+	d.buf, d.offset = d.buf[:0], 0
+	err := d.readElement(0)
+	if err == io.EOF && len(d.buf) > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readElement appends to the buffer of the Decoder exactly the bytes of the
+// next element available in its reader. The elements of a value using the
+// indefinite form are read up to its end-of-contents. depth is the number of
+// values using the indefinite form around the element.
+func (d *Decoder) readElement(depth int) error {
+	offset := len(d.buf)
+	if err := d.read(1); err != nil {
+		return err
+	}
+	if d.buf[offset]&0x1F == 0x1F {
+		for {
+			if err := d.read(1); err != nil {
+				return err
+			}
+			if d.buf[len(d.buf)-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	if err := d.read(1); err != nil {
+		return err
+	}
+	if c := d.buf[len(d.buf)-1]; c > 0x80 {
+		if err := d.read(int(c & 0x7F)); err != nil {
+			return err
+		}
+	}
+	_, n, err := decodeIdentifier(d.buf[offset:])
+	if err != nil {
+		return err
+	}
+	size, _, err := decodeLength(d.buf[offset+n:])
+	if err != nil {
+		return err
+	}
+	if size != indefinite {
+		if size < 0 {
+			return fmt.Errorf("invalid length %d", size)
+		}
+		return d.read(size)
+	}
+	if depth >= maxDepth {
+		err := fmt.Errorf("%w (more than %d levels)", ErrTooDeep, maxDepth)
+		return d.structuralError(offset, 0, err)
+	}
+	for {
+		pos := len(d.buf)
+		if err := d.readElement(depth + 1); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if isEOC(d.buf[pos:]) {
+			return nil
+		}
+	}
+}
+
+func (d *Decoder) read(n int) error {
+	for n > 0 {
+		z := n
+		if z > streamChunk {
+			z = streamChunk
+		}
+		offset := len(d.buf)
+		d.buf = append(d.buf, make([]byte, z)...)
+		if _, err := io.ReadFull(d.r, d.buf[offset:]); err != nil {
+			d.buf = d.buf[:offset]
+			if err == io.EOF && offset > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		n -= z
+	}
+	return nil
+}
+
+// flush writes the bytes encoded so far to the writer of a stream Encoder.
+func (e *Encoder) flush() error {
+	if e.err != nil || e.w == nil {
+		return e.err
+	}
+	if _, err := e.WriteTo(e.w); err != nil {
+		e.err, e.werr = err, err
+	}
+	return e.err
+}
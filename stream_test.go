@@ -0,0 +1,145 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	type Sample struct {
+		Name string
+		List []int
+	}
+	var (
+		e      Encoder
+		sample = Sample{Name: "foobar", List: []int{1, 2, 3}}
+	)
+	e.Encode(sample)
+	e.EncodeInt(42)
+	e.EncodeIndefinite(Sequence)
+	e.EncodeStringUTF8("ber")
+	e.EncodeEndOfContents()
+
+	var (
+		buf = e.Bytes()
+		rs  = bytes.NewReader(buf)
+		d   = NewStreamDecoder(rs)
+		got Sample
+	)
+	if err := d.Decode(&got); err != nil {
+		t.Errorf("stream: fail to decode struct! %s", err)
+		return
+	}
+	if !reflect.DeepEqual(got, sample) {
+		t.Errorf("stream: struct mismatched! want %+v, got %+v", sample, got)
+	}
+	if n := len(buf) - rs.Len(); n != 2+8+11 {
+		t.Errorf("stream: too many bytes read! %d", n)
+	}
+	if i, err := d.DecodeInt(); err != nil || i != 42 {
+		t.Errorf("stream: fail to decode int! %d (%v)", i, err)
+	}
+	var list []string
+	if err := d.Decode(&list); err != nil || len(list) != 1 || list[0] != "ber" {
+		t.Errorf("stream: fail to decode indefinite value! %v (%v)", list, err)
+	}
+	if _, err := d.DecodeInt(); err != io.EOF {
+		t.Errorf("stream: io.EOF expected! got %v", err)
+	}
+
+	d = NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(buf[:len(buf)-1])))
+	if err := d.Decode(&got); err != nil {
+		t.Errorf("stream: fail to decode struct! %s", err)
+	}
+	if err := d.Skip(); err != nil {
+		t.Errorf("stream: fail to skip int! %s", err)
+	}
+	if err := d.Skip(); err != io.ErrUnexpectedEOF {
+		t.Errorf("stream: io.ErrUnexpectedEOF expected! got %v", err)
+	}
+}
+
+// nestedReader gives an endless sequence of values using the indefinite form,
+// each one being the first element of the previous one.
+type nestedReader struct {
+	n int
+}
+
+func (r *nestedReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = []byte{0x30, 0x80}[r.n%2]
+		r.n++
+	}
+	return len(b), nil
+}
+
+func TestStreamDecoderDepth(t *testing.T) {
+	var (
+		r   nestedReader
+		d   = NewStreamDecoder(&r)
+		v   interface{}
+		err = d.Decode(&v)
+		se  *StructuralError
+	)
+	if !errors.As(err, &se) || !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("stream: depth error expected, got %T (%v)", err, err)
+	}
+	if r.n > 2*(maxDepth+1) {
+		t.Errorf("stream: too many bytes read! %d", r.n)
+	}
+}
+
+type recordWriter struct {
+	writes [][]byte
+}
+
+func (w *recordWriter) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte{}, b...))
+	return len(b), nil
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestStreamEncoder(t *testing.T) {
+	var (
+		w recordWriter
+		e = NewStreamEncoder(&w)
+	)
+	e.Encode([]int{1, 2})
+	e.EncodeBool(true)
+	e.EncodeIndefinite(Sequence)
+	e.EncodeNull()
+	if err := e.EncodeEndOfContents(); err != nil {
+		t.Errorf("stream: fail to encode! %s", err)
+		return
+	}
+	want := [][]byte{
+		{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02},
+		{0x01, 0x01, 0xff},
+		{0x30, 0x80},
+		{0x05, 0x00},
+		{0x00, 0x00},
+	}
+	if !reflect.DeepEqual(w.writes, want) {
+		t.Errorf("stream: writes mismatched! want %x, got %x", want, w.writes)
+	}
+	if b := e.Bytes(); len(b) != 0 {
+		t.Errorf("stream: %d bytes remained in buffer", len(b))
+	}
+
+	e = NewStreamEncoder(failWriter{})
+	if err := e.EncodeBool(true); err != io.ErrShortWrite {
+		t.Errorf("stream: write error should be reported! got %v", err)
+	}
+	if err := e.EncodeBool(true); err != io.ErrShortWrite {
+		t.Errorf("stream: write error should be kept! got %v", err)
+	}
+}
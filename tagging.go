@@ -0,0 +1,205 @@
+package ber
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Tagging selects how the tag given to a field of a struct is applied when the
+// field tag says neither explicit nor implicit. It corresponds to the tag
+// default of an ASN.1 module (X.680 13.1).
+type Tagging uint8
+
+const (
+	// the tag of a field replaces the identifier of its value
+	Implicit Tagging = iota
+	// the value of a field is wrapped in a constructed element with the tag of
+	// the field
+	Explicit
+	// the fields of a struct without any tag are given context specific tags
+	// numbered from 0 in their order of declaration. Tags are implicit except
+	// for interface fields.
+	Automatic
+)
+
+func (t Tagging) String() string {
+	switch t {
+	case Implicit:
+		return "IMPLICIT"
+	case Explicit:
+		return "EXPLICIT"
+	case Automatic:
+		return "AUTOMATIC"
+	default:
+		return "unknown"
+	}
+}
+
+// fieldTag describes how a field of a struct is encoded.
+type fieldTag struct {
+	// the field is not encoded (unexported or tagged with "-")
+	skip bool
+	// the field gives the identifier of its struct
+	ident bool
+	// identifier of the value of the field
+	id Ident
+	// identifier of the constructed element wrapping the value of the field
+	// with explicit tagging, zero otherwise
+	outer Ident
+	// the field is omitted when it has its zero value
+	omit bool
+	opts fieldOptions
+	// error found in the field tag
+	err error
+}
+
+// structTags describes the fields of the struct type typ. Invalid field tags are
+// reported by the err of their fieldTag.
+func structTags(typ reflect.Type, tagging Tagging) []fieldTag {
+	var (
+		list      = make([]fieldTag, typ.NumField())
+		automatic = tagging == Automatic
+	)
+	for i := range list {
+		sf := typ.Field(i)
+		switch str := sf.Tag.Get("ber"); {
+		case sf.PkgPath != "" || str == "-":
+			list[i].skip = true
+		case sf.Type == identtype && (sf.Name == "Id" || str == "id"):
+			list[i].ident = true
+		default:
+			automatic = automatic && !hasTagOption(str)
+		}
+	}
+	var index uint32
+	for i := range list {
+		if list[i].skip || list[i].ident {
+			continue
+		}
+		var (
+			sf  = typ.Field(i)
+			str = sf.Tag.Get("ber")
+			ft  = &list[i]
+			err error
+		)
+		def := identForKind[sf.Type.Kind()]
+		if sf.Type == oidtype {
+			def = ObjectId
+		}
+		if ft.opts, err = parseOptions(str); err == nil {
+			ft.id, ft.omit, err = parseTag(stripTagOptions(str), def)
+		}
+		if err != nil {
+			ft.err = err
+			continue
+		}
+		var (
+			tag      Ident
+			explicit = hasOption(str, "explicit")
+		)
+		switch {
+		case automatic:
+			base := ft.id
+			if base.isZero() {
+				base = typeIdent(sf.Type)
+			}
+			tag = retag(base, Context, index)
+			index++
+		case hasTagOption(str):
+			if tag, _, err = parseTag(keepTagOptions(str), ft.id); err != nil {
+				ft.err = err
+				continue
+			}
+			explicit = explicit || (tagging == Explicit && !hasOption(str, "implicit"))
+		default:
+			continue
+		}
+		// the tag of a CHOICE is always explicit (X.680 31.2.7)
+		explicit = explicit || sf.Type.Kind() == reflect.Interface
+		if !explicit {
+			ft.id = tag
+			continue
+		}
+		class := tag.Class()
+		if class == Universal {
+			class = Context
+		}
+		ft.outer = retag(0, class, tag.Tag()).Constructed()
+	}
+	return list
+}
+
+// typeIdent gives the universal identifier of the values of type typ. It is
+// zero for the types that can be decoded from any element.
+func typeIdent(typ reflect.Type) Ident {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == oidtype {
+		return ObjectId
+	}
+	if id := identForKind[typ.Kind()]; !id.isZero() {
+		return id
+	}
+	switch k := typ.Kind(); {
+	case typ == timetype:
+		return GeneralizedTime
+	case typ == bitstringtype:
+		return BitString
+	case typ == bigtype:
+		return Int
+	case typ == bytestype:
+		return OctetString
+	case k == reflect.Struct || k == reflect.Slice || k == reflect.Array || k == reflect.Map:
+		return Sequence
+	}
+	return 0
+}
+
+// retag gives the identifier with the type of id and the given class and tag
+// number.
+func retag(id Ident, class uint8, tag uint32) Ident {
+	return Ident(uint64(class)<<33 | uint64(id.Type())<<32 | uint64(tag))
+}
+
+// hasTagOption reports whether the field tag str gives the class or the number
+// of the tag of the field.
+func hasTagOption(str string) bool {
+	for _, str := range strings.Split(str, ",") {
+		if isTagOption(str) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOption(str, option string) bool {
+	for _, str := range strings.Split(str, ",") {
+		if str == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isTagOption(str string) bool {
+	return strings.HasPrefix(str, "tag:") || strings.HasPrefix(str, "class:")
+}
+
+func stripTagOptions(str string) string {
+	return filterOptions(str, false)
+}
+
+func keepTagOptions(str string) string {
+	return filterOptions(str, true)
+}
+
+func filterOptions(str string, keep bool) string {
+	var list []string
+	for _, str := range strings.Split(str, ",") {
+		if isTagOption(str) == keep {
+			list = append(list, str)
+		}
+	}
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,136 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTagging(t *testing.T) {
+	t.Run("explicit", testTaggingExplicit)
+	t.Run("module", testTaggingModule)
+	t.Run("automatic", testTaggingAutomatic)
+}
+
+func testTaggingExplicit(t *testing.T) {
+	type version struct {
+		Version int    `ber:"explicit,tag:0,class:2"`
+		Name    string `ber:"tag:1,class:2"`
+	}
+	in := version{Version: 2, Name: "ab"}
+
+	var e Encoder
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("explicit: fail to encode! %s", err)
+	}
+	want := []byte{0x30, 0x09, 0xa0, 0x03, 0x02, 0x01, 0x02, 0x81, 0x02, 0x61, 0x62}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("explicit: bytes mismatched! want %x, got %x", want, got)
+	}
+	var out version
+	if err := NewDecoder(want).Decode(&out); err != nil {
+		t.Fatalf("explicit: fail to decode! %s", err)
+	}
+	if out != in {
+		t.Errorf("explicit: values mismatched! want %+v, got %+v", in, out)
+	}
+	// the value is not wrapped in the explicit tag
+	implicit := []byte{0x30, 0x07, 0x80, 0x01, 0x02, 0x81, 0x02, 0x61, 0x62}
+	var se *StructuralError
+	if err := NewDecoder(implicit).Decode(&out); !errors.As(err, &se) {
+		t.Errorf("explicit: expected *StructuralError, got %T (%v)", err, err)
+	}
+}
+
+func testTaggingModule(t *testing.T) {
+	type module struct {
+		Name  string `ber:"tag:0,class:2"`
+		Count int    `ber:"implicit,tag:1,class:2"`
+		Flag  bool
+	}
+	in := module{Name: "a", Count: 3, Flag: true}
+
+	var e Encoder
+	e.SetTagging(Explicit)
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("module: fail to encode! %s", err)
+	}
+	want := []byte{0x30, 0x0b, 0xa0, 0x03, 0x0c, 0x01, 0x61, 0x81, 0x01, 0x03, 0x01, 0x01, 0xff}
+	if got := e.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("module: bytes mismatched! want %x, got %x", want, got)
+	}
+	var (
+		out module
+		d   = NewDecoder(want)
+	)
+	d.SetTagging(Explicit)
+	if err := d.Decode(&out); err != nil {
+		t.Fatalf("module: fail to decode! %s", err)
+	}
+	if out != in {
+		t.Errorf("module: values mismatched! want %+v, got %+v", in, out)
+	}
+
+	e = Encoder{}
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("module: fail to encode! %s", err)
+	}
+	d = NewDecoder(e.Bytes())
+	d.SetTagging(Explicit)
+	if err := d.Decode(&out); !errors.Is(err, ErrConstructed) {
+		t.Errorf("module: expected constructed error, got %v", err)
+	}
+}
+
+func testTaggingAutomatic(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type record struct {
+		Name  string
+		When  time.Time
+		Point point
+		Count int `ber:"optional"`
+		Flag  bool
+	}
+	in := record{
+		Name:  "a",
+		When:  time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC),
+		Point: point{X: 1, Y: 2},
+		Flag:  true,
+	}
+
+	var e Encoder
+	e.SetRules(DER)
+	e.SetTagging(Automatic)
+	if err := e.Encode(in); err != nil {
+		t.Fatalf("automatic: fail to encode! %s", err)
+	}
+	var (
+		buf  = e.Bytes()
+		want = [][]byte{
+			{0x80, 0x01, 0x61},
+			{0x81, 0x0f},
+			{0xa2, 0x06, 0x80, 0x01, 0x01, 0x81, 0x01, 0x02},
+			{0x84, 0x01, 0xff},
+		}
+	)
+	for _, w := range want {
+		if !bytes.Contains(buf, w) {
+			t.Errorf("automatic: %x not found in %x", w, buf)
+		}
+	}
+	var (
+		out record
+		d   = NewDecoder(buf)
+	)
+	d.SetRules(DER)
+	d.SetTagging(Automatic)
+	if err := d.Decode(&out); err != nil {
+		t.Fatalf("automatic: fail to decode! %s", err)
+	}
+	if out != in {
+		t.Errorf("automatic: values mismatched! want %+v, got %+v", in, out)
+	}
+}
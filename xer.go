@@ -0,0 +1,839 @@
+package ber
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalXER gives the BASIC-XER encoding (X.693) of v. The document is made of
+// an element named by the type of v whose contents are the value of v:
+//
+//	bool                   <true/> or <false/>
+//	integers, *big.Int     decimal digits
+//	floats                 decimal number or <PLUS-INFINITY/>, <MINUS-INFINITY/>, <NOT-A-NUMBER/>, -0
+//	string                 text, control characters as <nul/>, <soh/>...
+//	[]byte                 hexadecimal digits
+//	Bits, named bits       a 0 or a 1 for each bit
+//	OID                    dotted form of the OID
+//	time.Time              GeneralizedTime form of the time
+//	struct                 an element for each field named by the field
+//	slice, array           an element for each item named by its type
+//	map                    a key and a value element for each entry
+//	CHOICE                 an element named by the type of the alternative
+//
+// The fields of a struct are selected with the ber tags understood by the
+// Encoder: skipped fields are not written, optional fields and pointer fields
+// are not written when nil and omitempty fields are not written when zero. A
+// pointer field whose element is missing is left nil. The element of a
+// field is named by its xml tag or by the name of the field. The tags of the
+// fields are not part of the XER encoding.
+//
+// Types without a XER encoding (Raw, Tagged and the types implementing
+// Marshaler) are written as the hexadecimal digits of their BER encoding.
+func MarshalXER(v interface{}) ([]byte, error) {
+	var (
+		x   xerEncoder
+		val = reflect.ValueOf(v)
+	)
+	if !val.IsValid() {
+		return nil, fmt.Errorf("xer: nil value can not be encoded")
+	}
+	if err := x.encodeElement(xerTypeName(val), val, nil, false); err != nil {
+		return nil, rootPath(valueError(err), val.Type())
+	}
+	return x.buf.Bytes(), nil
+}
+
+// UnmarshalXER decodes the BASIC-XER encoding of a value written by MarshalXER
+// in the value pointed to by v. The name of the root element should be the name
+// given by MarshalXER to the type of v. Whitespace between elements and around
+// values other than strings is ignored.
+func UnmarshalXER(data []byte, v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("xer: non nil pointer expected (got %T)", v)
+	}
+	n, err := readXML(data)
+	if err != nil {
+		return err
+	}
+	if want := xerTypeName(val.Elem()); n.name != want {
+		return rootPath(valueError(fmt.Errorf("element %s expected (got %s)", want, n.name)), val.Type())
+	}
+	if err := decodeXER(val.Elem(), n, nil, false); err != nil {
+		return rootPath(valueError(err), val.Type())
+	}
+	return nil
+}
+
+// xerTypeName gives the name of the element of the value val written without
+// the name of a field: the name of its type or the XML name of the ASN.1 type
+// encoding it.
+func xerTypeName(val reflect.Value) string {
+	if val.Kind() == reflect.Interface && !val.IsNil() && !isChoice(val.Type()) {
+		val = val.Elem()
+	}
+	typ := val.Type()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ {
+	case timetype:
+		return "GeneralizedTime"
+	case bigtype:
+		return "INTEGER"
+	case bitstringtype:
+		return "BIT_STRING"
+	case oidtype:
+		return "OBJECT_IDENTIFIER"
+	}
+	if typ.Name() != "" && typ.PkgPath() != "" {
+		return typ.Name()
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "INTEGER"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	case reflect.String:
+		return "UTF8String"
+	case reflect.Slice, reflect.Array:
+		if typ == bytestype {
+			return "OCTET_STRING"
+		}
+		return "SEQUENCE_OF"
+	case reflect.Interface:
+		return "NULL"
+	default:
+		return "SEQUENCE"
+	}
+}
+
+func isChoice(typ reflect.Type) bool {
+	_, ok := registeredChoice(typ)
+	return ok
+}
+
+type xerEncoder struct {
+	buf bytes.Buffer
+}
+
+func (x *xerEncoder) encodeElement(name string, val reflect.Value, names []string, named bool) error {
+	if val.Kind() == reflect.Interface && !val.IsNil() && !isChoice(val.Type()) {
+		// a value of an open type is given in an element named by its type
+		x.start(name)
+		val = val.Elem()
+		if err := x.encodeElement(xerTypeName(val), val, nil, false); err != nil {
+			return err
+		}
+		x.end(name)
+		return nil
+	}
+	x.start(name)
+	if err := x.encode(val, names, named); err != nil {
+		return err
+	}
+	x.end(name)
+	return nil
+}
+
+func (x *xerEncoder) encode(val reflect.Value, names []string, named bool) error {
+	if !named {
+		names, named = registeredNamedBits(val.Type())
+	}
+	if named {
+		bs, err := namedBitString(val, names)
+		if err != nil {
+			return err
+		}
+		return x.encodeBits(bs)
+	}
+	switch typ := val.Type(); {
+	case typ == rawtype:
+		x.text(strings.ToUpper(hex.EncodeToString(val.Bytes())))
+		return nil
+	case typ == taggedtype:
+		var e Encoder
+		if err := e.encode(val.Interface(), 0); err != nil {
+			return err
+		}
+		x.text(strings.ToUpper(hex.EncodeToString(e.Bytes())))
+		return nil
+	case typ == timetype:
+		t := val.Interface().(time.Time)
+		x.text(t.UTC().Format(patGeneralTimeZ))
+		return nil
+	case typ == bitstringtype:
+		return x.encodeBits(val.Interface().(Bits))
+	case typ == bigtype:
+		b := val.Interface().(big.Int)
+		x.text(b.String())
+		return nil
+	case typ == oidtype:
+		x.text(val.String())
+		return nil
+	case val.Kind() != reflect.Ptr && val.Kind() != reflect.Interface && val.CanInterface() && typ.Implements(marshaltype):
+		buf, err := val.Interface().(Marshaler).Marshal()
+		if err != nil {
+			return err
+		}
+		x.text(strings.ToUpper(hex.EncodeToString(buf)))
+		return nil
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		x.empty(strconv.FormatBool(val.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x.text(strconv.FormatInt(val.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x.text(strconv.FormatUint(val.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		x.encodeFloat(val.Float())
+	case reflect.String:
+		x.text(val.String())
+	case reflect.Slice, reflect.Array:
+		if val.Type() == bytestype {
+			x.text(strings.ToUpper(hex.EncodeToString(val.Bytes())))
+			break
+		}
+		return x.encodeList(val)
+	case reflect.Map:
+		return x.encodeMap(val)
+	case reflect.Struct:
+		return x.encodeStruct(val)
+	case reflect.Ptr:
+		if val.IsNil() {
+			break
+		}
+		return x.encode(val.Elem(), nil, false)
+	case reflect.Interface:
+		if val.IsNil() {
+			break
+		}
+		c, _ := registeredChoice(val.Type())
+		alt := val.Elem()
+		if _, ok := c.ident(alt.Type()); !ok {
+			return fmt.Errorf("choice: %s is not an alternative of %s", alt.Type(), val.Type())
+		}
+		return x.encodeElement(choiceName(alt.Type()), alt, nil, false)
+	default:
+		return fmt.Errorf("%s can not be encoded in XML", val.Type())
+	}
+	return nil
+}
+
+func (x *xerEncoder) start(name string) {
+	x.buf.WriteString("<" + name + ">")
+}
+
+func (x *xerEncoder) end(name string) {
+	x.buf.WriteString("</" + name + ">")
+}
+
+func (x *xerEncoder) empty(name string) {
+	x.buf.WriteString("<" + name + "/>")
+}
+
+// text writes str escaped. The control characters that XML does not allow are
+// written as the empty elements named in X.680 (eg: <nul/>).
+func (x *xerEncoder) text(str string) {
+	for {
+		i := strings.IndexFunc(str, isXERControl)
+		if i < 0 {
+			break
+		}
+		xml.EscapeText(&x.buf, []byte(str[:i]))
+		x.empty(xerControls[str[i]])
+		str = str[i+1:]
+	}
+	xml.EscapeText(&x.buf, []byte(str))
+}
+
+// xerControls gives the names of the elements of the control characters.
+var xerControls = [...]string{
+	"nul", "soh", "stx", "etx", "eot", "enq", "ack", "bel",
+	"bs", "ht", "lf", "vt", "ff", "cr", "so", "si",
+	"dle", "dc1", "dc2", "dc3", "dc4", "nak", "syn", "etb",
+	"can", "em", "sub", "esc", "is4", "is3", "is2", "is1",
+}
+
+// isXERControl reports whether r is a control character written as an
+// element. Tabs and line breaks are written as character references.
+func isXERControl(r rune) bool {
+	return r < 0x20 && r != '\t' && r != '\n' && r != '\r'
+}
+
+// xerControl gives the control character written as the element named name.
+func xerControl(name string) (byte, bool) {
+	for i, str := range xerControls {
+		if str == name {
+			return byte(i), true
+		}
+	}
+	return 0, false
+}
+
+func (x *xerEncoder) encodeFloat(f float64) {
+	switch {
+	case math.IsInf(f, 1):
+		x.empty("PLUS-INFINITY")
+	case math.IsInf(f, -1):
+		x.empty("MINUS-INFINITY")
+	case math.IsNaN(f):
+		x.empty("NOT-A-NUMBER")
+	case f == 0 && math.Signbit(f):
+		x.text("-0")
+	default:
+		x.text(strconv.FormatFloat(f, 'G', -1, 64))
+	}
+}
+
+func (x *xerEncoder) encodeBits(bs Bits) error {
+	if _, err := encodeBitString(bs); err != nil {
+		return err
+	}
+	var str strings.Builder
+	for i := 0; i < bs.BitLength; i++ {
+		if bs.Bytes[i/8]&(0x80>>(i%8)) != 0 {
+			str.WriteByte('1')
+		} else {
+			str.WriteByte('0')
+		}
+	}
+	x.text(str.String())
+	return nil
+}
+
+// encodeList writes the items of val in elements named by their type. Booleans
+// are written without element around them (X.693 9.3.5).
+func (x *xerEncoder) encodeList(val reflect.Value) error {
+	for i := 0; i < val.Len(); i++ {
+		var (
+			v   = val.Index(i)
+			err error
+		)
+		switch {
+		case v.Kind() == reflect.Bool:
+			err = x.encode(v, nil, false)
+		case v.Kind() == reflect.Interface && !v.IsNil() && !isChoice(v.Type()):
+			v = v.Elem()
+			fallthrough
+		default:
+			err = x.encodeElement(xerTypeName(v), v, nil, false)
+		}
+		if err != nil {
+			return prefixPath(valueError(err), indexPath(i))
+		}
+	}
+	return nil
+}
+
+func (x *xerEncoder) encodeMap(val reflect.Value) error {
+	keys := val.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	for _, k := range keys {
+		if err := x.encodeElement("key", k, nil, false); err != nil {
+			return prefixPath(valueError(err), keyPath(k))
+		}
+		if err := x.encodeElement("value", val.MapIndex(k), nil, false); err != nil {
+			return prefixPath(valueError(err), keyPath(k))
+		}
+	}
+	return nil
+}
+
+func (x *xerEncoder) encodeStruct(val reflect.Value) error {
+	var (
+		typ  = val.Type()
+		tags = structTags(typ, Implicit)
+	)
+	for i := 0; i < val.NumField(); i++ {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident {
+			continue
+		}
+		if ft.err != nil {
+			return prefixPath(valueError(ft.err), sf.Name)
+		}
+		if ((ft.opts.optional || f.Kind() == reflect.Ptr) && isNil(f)) || (ft.omit && f.IsZero()) {
+			continue
+		}
+		names, named := namedBitsForField(sf)
+		if err := x.encodeElement(xerName(sf), f, names, named); err != nil {
+			return prefixPath(valueError(err), sf.Name)
+		}
+	}
+	return nil
+}
+
+func xerName(sf reflect.StructField) string {
+	str := sf.Tag.Get("xml")
+	if i := strings.Index(str, ","); i >= 0 {
+		str = str[:i]
+	}
+	if str == "" || str == "-" {
+		return sf.Name
+	}
+	return str
+}
+
+// xerNode is an element of a XER document.
+type xerNode struct {
+	name     string
+	text     string
+	children []*xerNode
+}
+
+// value gives the text of n without its surrounding whitespace.
+func (n *xerNode) value() string {
+	return strings.TrimSpace(n.text)
+}
+
+// only gives the single element of n.
+func (n *xerNode) only() (*xerNode, error) {
+	if len(n.children) != 1 {
+		return nil, fmt.Errorf("%s: one element expected (got %d)", n.name, len(n.children))
+	}
+	return n.children[0], nil
+}
+
+func readXML(data []byte) (*xerNode, error) {
+	var (
+		dec   = xml.NewDecoder(bytes.NewReader(data))
+		stack []*xerNode
+		root  *xerNode
+	)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xer: %w", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if root != nil && len(stack) == 0 {
+				return nil, fmt.Errorf("xer: data after root element")
+			}
+			if len(stack) >= maxDepth {
+				return nil, fmt.Errorf("xer: %w (more than %d levels)", ErrTooDeep, maxDepth)
+			}
+			n := &xerNode{name: tok.Name.Local}
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 || len(n.children) > 0 || n.text != "" {
+				break
+			}
+			// an empty element named by a control character in a text stands
+			// for this character
+			if c, ok := xerControl(n.name); ok {
+				stack[len(stack)-1].text += string(c)
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			} else if len(bytes.TrimSpace(tok)) > 0 {
+				return nil, fmt.Errorf("xer: text outside of root element")
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("xer: no root element")
+	}
+	return root, nil
+}
+
+func decodeXER(val reflect.Value, n *xerNode, names []string, named bool) error {
+	if !named {
+		names, named = registeredNamedBits(val.Type())
+	}
+	if named {
+		bs, err := xerBits(n.value())
+		if err != nil {
+			return err
+		}
+		return setNamedBits(val, names, bs)
+	}
+	switch typ := val.Type(); {
+	case typ == rawtype:
+		buf, err := hex.DecodeString(n.value())
+		if err == nil {
+			val.SetBytes(buf)
+		}
+		return err
+	case typ == taggedtype:
+		buf, err := hex.DecodeString(n.value())
+		if err != nil {
+			return err
+		}
+		return NewDecoder(buf).Decode(val.Addr().Interface())
+	case typ == timetype:
+		str := n.value()
+		t, err := time.Parse(patGeneralTimeParse, str)
+		if err != nil {
+			t, err = time.Parse(patUniversTimeParse, str)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: invalid time", str)
+		}
+		val.Set(reflect.ValueOf(t.UTC()))
+		return nil
+	case typ == bitstringtype:
+		bs, err := xerBits(n.value())
+		if err == nil {
+			val.Set(reflect.ValueOf(bs))
+		}
+		return err
+	case typ == bigtype:
+		b, ok := new(big.Int).SetString(n.value(), 10)
+		if !ok {
+			return fmt.Errorf("%s: invalid integer", n.value())
+		}
+		val.Addr().Interface().(*big.Int).Set(b)
+		return nil
+	case typ == oidtype:
+		val.SetString(n.value())
+		return nil
+	case val.Kind() != reflect.Ptr && val.Kind() != reflect.Interface && reflect.PtrTo(typ).Implements(unmarshaltype):
+		buf, err := hex.DecodeString(n.value())
+		if err != nil {
+			return err
+		}
+		return val.Addr().Interface().(Unmarshaler).Unmarshal(buf)
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		c, err := n.only()
+		if err != nil {
+			return err
+		}
+		return decodeXERBool(val, c)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(n.value(), 10, 64)
+		if errors.Is(err, strconv.ErrRange) || (err == nil && val.OverflowInt(i)) {
+			return fmt.Errorf("%s: %w", n.value(), ErrOverflow)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer", n.value())
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(n.value(), 10, 64)
+		if errors.Is(err, strconv.ErrRange) || (err == nil && val.OverflowUint(i)) {
+			return fmt.Errorf("%s: %w", n.value(), ErrOverflow)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer", n.value())
+		}
+		val.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := xerFloat(n)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.String:
+		val.SetString(n.text)
+	case reflect.Slice:
+		if val.Type() == bytestype {
+			buf, err := hex.DecodeString(n.value())
+			if err != nil {
+				return err
+			}
+			val.SetBytes(buf)
+			break
+		}
+		val.Set(reflect.MakeSlice(val.Type(), len(n.children), len(n.children)))
+		return decodeXERList(val, n)
+	case reflect.Array:
+		if len(n.children) != val.Len() {
+			return fmt.Errorf("%d elements can not be decoded into %s", len(n.children), val.Type())
+		}
+		return decodeXERList(val, n)
+	case reflect.Map:
+		return decodeXERMap(val, n)
+	case reflect.Struct:
+		return decodeXERStruct(val, n)
+	case reflect.Ptr:
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		return decodeXER(val.Elem(), n, nil, false)
+	case reflect.Interface:
+		if len(n.children) == 0 && n.value() == "" {
+			val.Set(reflect.Zero(val.Type()))
+			break
+		}
+		c, err := n.only()
+		if err != nil {
+			return err
+		}
+		if ch, ok := registeredChoice(val.Type()); ok {
+			typ, ok := ch.named(c.name)
+			if !ok {
+				return fmt.Errorf("choice: %s is not an alternative of %s", c.name, val.Type())
+			}
+			alt := reflect.New(typ).Elem()
+			if err := decodeXER(alt, c, nil, false); err != nil {
+				return err
+			}
+			val.Set(alt)
+			break
+		}
+		if val.NumMethod() > 0 {
+			return fmt.Errorf("no alternative registered for %s", val.Type())
+		}
+		v, err := xerGeneric(c)
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			val.Set(reflect.ValueOf(v))
+		}
+	default:
+		return fmt.Errorf("XML can not be decoded into %s", val.Type())
+	}
+	return nil
+}
+
+func decodeXERBool(val reflect.Value, n *xerNode) error {
+	switch n.name {
+	case "true":
+		val.SetBool(true)
+	case "false":
+		val.SetBool(false)
+	default:
+		return fmt.Errorf("%s: invalid boolean", n.name)
+	}
+	return nil
+}
+
+func decodeXERList(val reflect.Value, n *xerNode) error {
+	for i, c := range n.children {
+		var (
+			v   = val.Index(i)
+			err error
+		)
+		switch {
+		case v.Kind() == reflect.Bool:
+			err = decodeXERBool(v, c)
+		case v.Kind() == reflect.Interface && !isChoice(v.Type()) && v.NumMethod() == 0:
+			var x interface{}
+			if x, err = xerGeneric(c); err == nil && x != nil {
+				v.Set(reflect.ValueOf(x))
+			}
+		default:
+			if want := xerTypeName(v); c.name != want {
+				err = fmt.Errorf("element %s expected (got %s)", want, c.name)
+				break
+			}
+			err = decodeXER(v, c, nil, false)
+		}
+		if err != nil {
+			return prefixPath(valueError(err), indexPath(i))
+		}
+	}
+	return nil
+}
+
+func decodeXERMap(val reflect.Value, n *xerNode) error {
+	typ := val.Type()
+	if len(n.children)%2 != 0 {
+		return fmt.Errorf("%s: key without value", n.name)
+	}
+	if val.IsNil() {
+		val.Set(reflect.MakeMapWithSize(typ, len(n.children)/2))
+	}
+	for i := 0; i < len(n.children); i += 2 {
+		kn, vn := n.children[i], n.children[i+1]
+		if kn.name != "key" || vn.name != "value" {
+			return fmt.Errorf("%s: key and value expected (got %s and %s)", n.name, kn.name, vn.name)
+		}
+		var (
+			key = reflect.New(typ.Key()).Elem()
+			v   = reflect.New(typ.Elem()).Elem()
+		)
+		if err := decodeXER(key, kn, nil, false); err != nil {
+			return prefixPath(valueError(err), indexPath(i/2))
+		}
+		if err := decodeXER(v, vn, nil, false); err != nil {
+			return prefixPath(valueError(err), keyPath(key))
+		}
+		val.SetMapIndex(key, v)
+	}
+	return nil
+}
+
+func decodeXERStruct(val reflect.Value, n *xerNode) error {
+	var (
+		typ    = val.Type()
+		tags   = structTags(typ, Implicit)
+		fields = make(map[string]*xerNode)
+	)
+	for _, c := range n.children {
+		if _, ok := fields[c.name]; ok {
+			return fmt.Errorf("%s: duplicate element", c.name)
+		}
+		fields[c.name] = c
+	}
+	for i := 0; i < val.NumField(); i++ {
+		var (
+			f  = val.Field(i)
+			sf = typ.Field(i)
+			ft = tags[i]
+		)
+		if ft.skip || ft.ident || !f.CanSet() {
+			continue
+		}
+		if ft.err != nil {
+			return prefixPath(valueError(ft.err), sf.Name)
+		}
+		c, ok := fields[xerName(sf)]
+		if !ok {
+			switch {
+			case ft.opts.hasDef:
+				def, err := defaultValue(f.Type(), ft.opts.def)
+				if err != nil {
+					return prefixPath(valueError(err), sf.Name)
+				}
+				f.Set(def)
+			case !ft.opts.optional && !ft.omit && f.Kind() != reflect.Ptr:
+				return prefixPath(valueError(fmt.Errorf("missing element %s", xerName(sf))), sf.Name)
+			}
+			continue
+		}
+		delete(fields, c.name)
+		names, named := namedBitsForField(sf)
+		if err := decodeXER(f, c, names, named); err != nil {
+			return prefixPath(valueError(err), sf.Name)
+		}
+	}
+	for name := range fields {
+		return fmt.Errorf("%s: unknown element in %s", name, typ)
+	}
+	return nil
+}
+
+func xerBits(str string) (Bits, error) {
+	var bs Bits
+	for i, c := range str {
+		if i%8 == 0 {
+			bs.Bytes = append(bs.Bytes, 0)
+		}
+		switch c {
+		case '1':
+			bs.Bytes[i/8] |= 0x80 >> (i % 8)
+		case '0':
+		default:
+			return bs, fmt.Errorf("%s: invalid bit string", str)
+		}
+		bs.BitLength++
+	}
+	return bs, nil
+}
+
+func xerFloat(n *xerNode) (float64, error) {
+	if len(n.children) == 0 {
+		f, err := strconv.ParseFloat(n.value(), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: invalid real", n.value())
+		}
+		return f, nil
+	}
+	c, err := n.only()
+	if err != nil {
+		return 0, err
+	}
+	switch c.name {
+	case "PLUS-INFINITY":
+		return math.Inf(1), nil
+	case "MINUS-INFINITY":
+		return math.Inf(-1), nil
+	case "NOT-A-NUMBER":
+		return math.NaN(), nil
+	default:
+		return 0, fmt.Errorf("%s: invalid real", c.name)
+	}
+}
+
+// xerGeneric gives the Go value of the element n named by the XML name of an
+// ASN.1 type. The values are those given by Decoder.Decode for an empty
+// interface.
+func xerGeneric(n *xerNode) (interface{}, error) {
+	switch n.name {
+	case "NULL":
+		return nil, nil
+	case "BOOLEAN":
+		var b bool
+		c, err := n.only()
+		if err == nil {
+			err = decodeXERBool(reflect.ValueOf(&b).Elem(), c)
+		}
+		return b, err
+	case "INTEGER":
+		if i, err := strconv.ParseInt(n.value(), 10, 64); err == nil {
+			return i, nil
+		}
+		b, ok := new(big.Int).SetString(n.value(), 10)
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid integer", n.value())
+		}
+		return b, nil
+	case "REAL":
+		return xerFloat(n)
+	case "UTF8String":
+		return n.text, nil
+	case "OCTET_STRING":
+		return hex.DecodeString(n.value())
+	case "BIT_STRING":
+		return xerBits(n.value())
+	case "OBJECT_IDENTIFIER":
+		return OID(n.value()), nil
+	case "GeneralizedTime":
+		var t time.Time
+		err := decodeXER(reflect.ValueOf(&t).Elem(), n, nil, false)
+		return t, err
+	case "SEQUENCE_OF":
+		list := make([]interface{}, len(n.children))
+		for i, c := range n.children {
+			v, err := xerGeneric(c)
+			if err != nil {
+				return nil, prefixPath(valueError(err), indexPath(i))
+			}
+			list[i] = v
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown type", n.name)
+	}
+}
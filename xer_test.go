@@ -0,0 +1,259 @@
+package ber
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type xerSample struct {
+	Version int64 `xml:"version"`
+	Name    string
+	Valid   bool
+	Serial  *big.Int
+	Ratio   float64
+	Data    []byte
+	Usage   keyUsage
+	Bits    Bits
+	Algo    OID
+	When    time.Time
+	Shape   choiceShape
+	Tags    []string
+	Flags   []bool
+	Any     interface{}
+	Attrs   map[string]int
+	Extra   *string `ber:"optional"`
+	Count   int     `ber:"default:3"`
+	Skip    int     `ber:"-"`
+}
+
+func TestXER(t *testing.T) {
+	t.Run("marshal", testMarshalXER)
+	t.Run("unmarshal", testUnmarshalXER)
+	t.Run("reals", testXERReals)
+	t.Run("generic", testXERGeneric)
+	t.Run("pointers", testXERPointers)
+	t.Run("controls", testXERControls)
+	t.Run("errors", testXERErrors)
+}
+
+var (
+	xerValue = xerSample{
+		Version: 2,
+		Name:    "a<b",
+		Valid:   true,
+		Serial:  bigInt("123456789012345678901234567890"),
+		Ratio:   0.5,
+		Data:    []byte{0xde, 0xad},
+		Usage:   digitalSignature | keyCertSign,
+		Bits:    Bits{Bytes: []byte{0xa0}, BitLength: 3},
+		Algo:    OID("1.2.840.113549"),
+		When:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Shape:   &choiceRect{Width: 3, Height: 4},
+		Tags:    []string{"a", "b"},
+		Flags:   []bool{true, false},
+		Any:     int64(7),
+		Attrs:   map[string]int{"y": 2, "x": 1},
+		Count:   3,
+	}
+	xerText = `<xerSample><version>2</version><Name>a&lt;b</Name><Valid><true/></Valid>` +
+		`<Serial>123456789012345678901234567890</Serial><Ratio>0.5</Ratio><Data>DEAD</Data>` +
+		`<Usage>100001</Usage><Bits>101</Bits><Algo>1.2.840.113549</Algo>` +
+		`<When>20210304050607Z</When><Shape><choiceRect><Width>3</Width><Height>4</Height></choiceRect></Shape>` +
+		`<Tags><UTF8String>a</UTF8String><UTF8String>b</UTF8String></Tags><Flags><true/><false/></Flags>` +
+		`<Any><INTEGER>7</INTEGER></Any><Attrs><key>x</key><value>1</value><key>y</key><value>2</value></Attrs>` +
+		`<Count>3</Count></xerSample>`
+)
+
+func testMarshalXER(t *testing.T) {
+	got, err := MarshalXER(xerValue)
+	if err != nil {
+		t.Fatalf("marshal: fail to marshal value! %s", err)
+	}
+	if string(got) != xerText {
+		t.Errorf("marshal: xml mismatched!\nwant %s\ngot  %s", xerText, got)
+	}
+}
+
+func testUnmarshalXER(t *testing.T) {
+	var got xerSample
+	if err := UnmarshalXER([]byte(xerText), &got); err != nil {
+		t.Fatalf("unmarshal: fail to unmarshal value! %s", err)
+	}
+	if !reflect.DeepEqual(got, xerValue) {
+		t.Errorf("unmarshal: value mismatched! want %+v, got %+v", xerValue, got)
+	}
+
+	type Sample struct {
+		Version int
+		Count   int `ber:"default:3"`
+		Name    string
+	}
+	var (
+		str  = "<Sample>\n  <Version> 1 </Version>\n  <Name> x </Name>\n</Sample>"
+		want = Sample{Version: 1, Count: 3, Name: " x "}
+		s    Sample
+	)
+	if err := UnmarshalXER([]byte(str), &s); err != nil {
+		t.Fatalf("unmarshal: fail to unmarshal indented value! %s", err)
+	}
+	if s != want {
+		t.Errorf("unmarshal: value mismatched! want %+v, got %+v", want, s)
+	}
+}
+
+func testXERReals(t *testing.T) {
+	data := []struct {
+		Value float64
+		Want  string
+	}{
+		{Value: 1.25, Want: "<REAL>1.25</REAL>"},
+		{Value: 1e21, Want: "<REAL>1E+21</REAL>"},
+		{Value: math.Inf(1), Want: "<REAL><PLUS-INFINITY/></REAL>"},
+		{Value: math.Inf(-1), Want: "<REAL><MINUS-INFINITY/></REAL>"},
+		{Value: math.NaN(), Want: "<REAL><NOT-A-NUMBER/></REAL>"},
+		{Value: math.Copysign(0, -1), Want: "<REAL>-0</REAL>"},
+	}
+	for _, d := range data {
+		got, err := MarshalXER(d.Value)
+		if err != nil {
+			t.Errorf("reals: fail to marshal %f! %s", d.Value, err)
+			continue
+		}
+		if string(got) != d.Want {
+			t.Errorf("reals: xml mismatched! want %s, got %s", d.Want, got)
+			continue
+		}
+		var f float64
+		if err := UnmarshalXER(got, &f); err != nil {
+			t.Errorf("reals: fail to unmarshal %s! %s", got, err)
+			continue
+		}
+		if math.Float64bits(f) != math.Float64bits(d.Value) && !(math.IsNaN(f) && math.IsNaN(d.Value)) {
+			t.Errorf("reals: value mismatched! want %f, got %f", d.Value, f)
+		}
+	}
+}
+
+func testXERGeneric(t *testing.T) {
+	in := []interface{}{int64(1), "str", true, nil, OID("1.2.3"), []interface{}{1.5}}
+	str, err := MarshalXER(in)
+	if err != nil {
+		t.Fatalf("generic: fail to marshal value! %s", err)
+	}
+	want := `<SEQUENCE_OF><INTEGER>1</INTEGER><UTF8String>str</UTF8String><BOOLEAN><true/></BOOLEAN>` +
+		`<NULL></NULL><OBJECT_IDENTIFIER>1.2.3</OBJECT_IDENTIFIER><SEQUENCE_OF><REAL>1.5</REAL></SEQUENCE_OF></SEQUENCE_OF>`
+	if string(str) != want {
+		t.Fatalf("generic: xml mismatched!\nwant %s\ngot  %s", want, str)
+	}
+	var got []interface{}
+	if err := UnmarshalXER(str, &got); err != nil {
+		t.Fatalf("generic: fail to unmarshal value! %s", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("generic: value mismatched! want %#v, got %#v", in, got)
+	}
+}
+
+func testXERPointers(t *testing.T) {
+	type Sample struct {
+		Name  *string
+		Count *int
+		Value *big.Int
+	}
+	var (
+		count = 0
+		in    = Sample{Count: &count}
+		want  = "<Sample><Count>0</Count></Sample>"
+	)
+	str, err := MarshalXER(in)
+	if err != nil {
+		t.Fatalf("pointers: fail to marshal value! %s", err)
+	}
+	if string(str) != want {
+		t.Fatalf("pointers: xml mismatched! want %s, got %s", want, str)
+	}
+	var got Sample
+	if err := UnmarshalXER(str, &got); err != nil {
+		t.Fatalf("pointers: fail to unmarshal value! %s", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("pointers: value mismatched! want %+v, got %+v", in, got)
+	}
+}
+
+func testXERControls(t *testing.T) {
+	data := []struct {
+		Value string
+		Want  string
+	}{
+		{Value: "a\x00b", Want: "<UTF8String>a<nul/>b</UTF8String>"},
+		{Value: "\x1b[0m\x1f", Want: "<UTF8String><esc/>[0m<is1/></UTF8String>"},
+		{Value: "a\tb\r\n", Want: "<UTF8String>a&#x9;b&#xD;&#xA;</UTF8String>"},
+		{Value: "<\x07>", Want: "<UTF8String>&lt;<bel/>&gt;</UTF8String>"},
+	}
+	for _, d := range data {
+		got, err := MarshalXER(d.Value)
+		if err != nil {
+			t.Errorf("controls: fail to marshal %q! %s", d.Value, err)
+			continue
+		}
+		if string(got) != d.Want {
+			t.Errorf("controls: xml mismatched! want %s, got %s", d.Want, got)
+			continue
+		}
+		var str string
+		if err := UnmarshalXER(got, &str); err != nil {
+			t.Errorf("controls: fail to unmarshal %s! %s", got, err)
+			continue
+		}
+		if str != d.Value {
+			t.Errorf("controls: value mismatched! want %q, got %q", d.Value, str)
+		}
+	}
+}
+
+type xerNext struct {
+	Next *xerNext `ber:"optional"`
+}
+
+func xerNested(n int) string {
+	return "<xerNext>" + strings.Repeat("<Next>", n) + strings.Repeat("</Next>", n) + "</xerNext>"
+}
+
+func testXERErrors(t *testing.T) {
+	data := []struct {
+		Name  string
+		Input string
+		Value interface{}
+		Err   error
+	}{
+		{Name: "overflow", Input: "<INTEGER>300</INTEGER>", Value: new(int8), Err: ErrOverflow},
+		{Name: "root", Input: "<REAL>1</REAL>", Value: new(int)},
+		{Name: "missing", Input: "<xerSample><Name>test</Name></xerSample>", Value: new(xerSample)},
+		{Name: "unknown", Input: "<SEQUENCE><A>1</A></SEQUENCE>", Value: new(struct{})},
+		{Name: "choice", Input: "<SEQUENCE><Shape><unknown/></Shape></SEQUENCE>", Value: new(struct{ Shape choiceShape })},
+		{Name: "boolean", Input: "<BOOLEAN>true</BOOLEAN>", Value: new(bool)},
+		{Name: "bits", Input: "<BIT_STRING>012</BIT_STRING>", Value: new(Bits)},
+		{Name: "trailing", Input: "<INTEGER>1</INTEGER><INTEGER>2</INTEGER>", Value: new(int)},
+		{Name: "malformed", Input: "<INTEGER>1</REAL>", Value: new(int)},
+		{Name: "depth", Input: xerNested(100000), Value: new(xerNext), Err: ErrTooDeep},
+	}
+	if _, err := MarshalXER(Bits{BitLength: 16}); err == nil {
+		t.Errorf("bits: expected error, got nil")
+	}
+	for _, d := range data {
+		err := UnmarshalXER([]byte(d.Input), d.Value)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", d.Name)
+			continue
+		}
+		if d.Err != nil && !errors.Is(err, d.Err) {
+			t.Errorf("%s: unexpected error! want %s, got %s", d.Name, d.Err, err)
+		}
+	}
+}